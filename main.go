@@ -2,37 +2,467 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"syscall"
 
-	anthropic "github.com/anthropics/anthropic-sdk-go"
 	"github.com/MarkusZoppelt/oen/pkg/agent"
+	"github.com/MarkusZoppelt/oen/pkg/config"
+	"github.com/MarkusZoppelt/oen/pkg/mcp"
 	"github.com/MarkusZoppelt/oen/pkg/tools"
 )
 
+// version and commit are stamped at build time, e.g.
+//
+//	go build -ldflags "-X main.version=v1.2.0 -X main.commit=$(git rev-parse --short HEAD)"
+//
+// version is also reported to MCP clients by "oen serve".
+var (
+	version = "dev"
+	commit  = ""
+)
+
+// multiLineMarker opens and closes a multi-line message in the interactive chat
+const multiLineMarker = `"""`
+
+// toolList is a flag that collects tool names from repeated and comma-separated values
+type toolList []string
+
+func (l *toolList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *toolList) Set(value string) error {
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			*l = append(*l, name)
+		}
+	}
+	return nil
+}
+
+// pathList is a flag that collects one path per use, since paths may contain commas
+type pathList []string
+
+func (l *pathList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *pathList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
 func main() {
-	client := anthropic.NewClient()
+	showVersion := flag.Bool("version", false, "Print version information and exit")
+	flag.BoolVar(showVersion, "v", false, "Shorthand for --version")
+	configFile := flag.String("config", "", "Path to a config file (defaults to ./oen.yaml, then ~/.config/oen/oen.yaml)")
+	sessionFile := flag.String("session", "", "Path to a session file to resume from and save the conversation to")
+	yes := flag.Bool("yes", false, "Run destructive tools without asking for confirmation")
+	dryRun := flag.Bool("dry-run", false, "Report what file-mutating tools would do without changing anything")
+	backup := flag.Bool("backup", false, "Copy files to <path>.bak before file-editing tools change them")
+	trash := flag.Bool("trash", false, "Move deleted directories to .oen-trash instead of removing them")
+	externalSymlinks := flag.Bool("allow-external-symlinks", false, "Let the symlink tool create links pointing outside the working directory, which other tools can then follow")
+	systemFile := flag.String("system-file", "", "Path to a file containing the system prompt (overrides OEN_SYSTEM_PROMPT)")
+	thinkingBudget := flag.Int64("thinking-budget", 0, "Enable extended thinking with up to this many tokens per response (at least 1024)")
+	showThinking := flag.Bool("show-thinking", false, "Print the model's extended thinking instead of hiding it")
+	redactSecrets := flag.Bool("redact-secrets", false, "Mask API keys, tokens, and other likely secrets in tool output before the model sees it")
+	quiet := flag.Bool("quiet", false, "Only print the model's answers and errors, not every tool call")
+	flag.BoolVar(quiet, "q", false, "Shorthand for --quiet")
+	verbose := flag.Bool("verbose", false, "Print token usage and estimated cost after every response")
+	timeout := flag.Duration("timeout", 0, "End the session after this long, e.g. 10m, canceling the running request or tool and saving the session")
+	maxTurns := flag.Int("model-max-turns", agent.DefaultMaxTurns, "Maximum model round-trips per user message before giving up")
+	readOnly := flag.Bool("read-only", false, "Only enable tools that don't change files")
+	var enableTools, disableTools toolList
+	flag.Var(&enableTools, "enable-tool", "Only enable the named tool; may be repeated or comma-separated")
+	flag.Var(&disableTools, "disable-tool", "Disable the named tool; may be repeated or comma-separated")
+	var contextFiles pathList
+	flag.Var(&contextFiles, "context-file", "Send the content of this file along with the first message; may be repeated")
+	transactional := flag.Bool("transactional", false, "Roll back a response's file edits if one of its tool calls that changes files fails")
+	toolStats := flag.Bool("tool-stats", false, "Print how often each tool was called and how long the calls took on exit")
+	watch := flag.Bool("watch", false, "Tell the model which files changed outside the chat before every message")
+	output := flag.String("output", "text", "Output format of non-interactive runs: text, or json for a single JSON object with the final answer, tool calls, and usage")
+	var prompt string
+	flag.StringVar(&prompt, "p", "", "Shorthand for --prompt")
+	flag.StringVar(&prompt, "prompt", "", "Run a single prompt non-interactively and exit once the model is done")
+	flag.Parse()
 
-	scanner := bufio.NewScanner(os.Stdin)
-	getUserMessage := func() (string, bool) {
-		if !scanner.Scan() {
+	if *showVersion {
+		fmt.Println(versionString())
+		return
+	}
+
+	// Flags override the config file, which overrides the environment.
+	file, filePath, err := loadConfigFile(*configFile)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+	if filePath != "" {
+		if tools.ConfigFile, err = filepath.Abs(filePath); err != nil {
+			fmt.Printf("Error: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	if err := tools.SetRoots(file.Root, file.Workspace); err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+	flags := config.Flags{
+		ThinkingBudget: *thinkingBudget,
+		RedactSecrets:  *redactSecrets,
+		EnabledTools:   enableTools,
+		DisabledTools:  disableTools,
+		ReadOnly:       *readOnly,
+	}
+	enableTools, disableTools = file.Tools(flags)
+
+	tools.DryRun = *dryRun
+	tools.Backup = *backup
+	tools.Trash = *trash
+	tools.AllowExternalSymlinks = *externalSymlinks
+	if vet := os.Getenv("OEN_VET_COMMAND"); vet != "" {
+		tools.VetCommand = strings.Fields(vet)
+	}
+	if file.VetCommand != "" {
+		tools.VetCommand = strings.Fields(file.VetCommand)
+	}
+	if dir := os.Getenv("OEN_TEMPLATES_DIR"); dir != "" {
+		tools.TemplatesDir = dir
+	}
+	if file.TemplatesDir != "" {
+		tools.TemplatesDir = file.TemplatesDir
+	}
+	if file.MaxRemoveEntries != 0 {
+		tools.MaxRemoveEntries = file.MaxRemoveEntries
+	}
+	if allowed := os.Getenv("OEN_ALLOWED_COMMANDS"); allowed != "" {
+		var commands []string
+		for _, command := range strings.Split(allowed, ",") {
+			if command = strings.TrimSpace(command); command != "" {
+				commands = append(commands, command)
+			}
+		}
+		agent.DefaultRegistry.Register(tools.NewRunCommandDefinition(commands))
+	}
+	if *readOnly {
+		enableTools = append(enableTools, tools.ReadOnlyTools...)
+	}
+	selectedTools, err := agent.DefaultRegistry.Select(enableTools, disableTools)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+	// Changes are only kept for undo if the undo tool, which /undo runs, can restore them.
+	tools.RecordUndo = slices.ContainsFunc(selectedTools, func(tool agent.ToolDefinition) bool {
+		return tool.Name == tools.UndoDefinition.Name
+	})
+
+	// "oen serve" exposes the tools to other agents instead of starting a chat.
+	if flag.Arg(0) == "serve" {
+		runner, err := newToolRunner(file, flags, selectedTools, *yes)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(1)
+		}
+		server := mcp.NewServer("oen", version, selectedTools, runner)
+		if err := server.Serve(context.Background(), os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Piped stdin is treated as the prompt, or appended to it when --prompt is also given.
+	if stat, err := os.Stdin.Stat(); err == nil && stat.Mode()&os.ModeCharDevice == 0 {
+		piped, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+			os.Exit(1)
+		}
+		if input := strings.TrimSpace(string(piped)); input != "" {
+			prompt = strings.TrimSpace(prompt + "\n\n" + input)
+		}
+	}
+
+	if *output != "text" && *output != "json" {
+		fmt.Printf("Error: unknown output format %q; valid options: text, json\n", *output)
+		os.Exit(1)
+	}
+	if *output == "json" && prompt == "" {
+		fmt.Println("Error: --output json requires --prompt or piped input")
+		os.Exit(1)
+	}
+	// In JSON mode the human-readable output goes to stderr so that stdout holds only the result.
+	var out io.Writer = os.Stdout
+	if *output == "json" {
+		out = os.Stderr
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if *timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	// Lines are read in the background so that waiting for input can be interrupted.
+	// A bufio.Reader is used rather than a Scanner because pasted prompts can exceed any fixed line limit.
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		reader := bufio.NewReader(os.Stdin)
+		for {
+			line, err := reader.ReadString('\n')
+			if line != "" {
+				lines <- strings.TrimRight(line, "\r\n")
+			}
+			if err != nil {
+				if err != io.EOF {
+					fmt.Fprintf(os.Stderr, "Error reading input: %s\n", err)
+				}
+				return
+			}
+		}
+	}()
+	readLine := func() (string, bool) {
+		select {
+		case line, ok := <-lines:
+			return line, ok
+		case <-ctx.Done():
 			return "", false
 		}
-		return scanner.Text(), true
+	}
+	// A line with just """ starts a multi-line message that ends at the next such line.
+	getUserMessage := func() (string, bool) {
+		line, ok := readLine()
+		if !ok || strings.TrimSpace(line) != multiLineMarker {
+			return line, ok
+		}
+		var message []string
+		for {
+			line, ok := readLine()
+			if !ok {
+				if ctx.Err() != nil {
+					return "", false
+				}
+				// Input ended before the closing marker, so send what was typed.
+				return strings.Join(message, "\n"), true
+			}
+			if strings.TrimSpace(line) == multiLineMarker {
+				return strings.Join(message, "\n"), true
+			}
+			message = append(message, line)
+		}
 	}
 
-	toolsList := []agent.ToolDefinition{
-		tools.ReadFileDefinition,
-		tools.ListFilesDefinition,
-		tools.EditFileDefinition,
-		tools.MakeDirectoryDefinition,
-		tools.RemoveDirectoryDefinition,
-		tools.RenameDirectoryDefinition,
+	if *systemFile != "" {
+		systemPrompt, err := os.ReadFile(*systemFile)
+		if err != nil {
+			fmt.Fprintf(out, "Error: %s\n", err)
+			os.Exit(1)
+		}
+		flags.SystemPrompt = string(systemPrompt)
+	}
+	config, err := file.AgentConfig(flags)
+	if err != nil {
+		fmt.Fprintf(out, "Error: %s\n", err)
+		os.Exit(1)
+	}
+	config.ShowThinking = *showThinking
+	if len(contextFiles) > 0 {
+		limit := config.MaxToolOutput
+		if limit <= 0 {
+			limit = agent.DefaultMaxToolOutput
+		}
+		config.InitialContext, err = readContextFiles(contextFiles, limit)
+		if err != nil {
+			fmt.Fprintf(out, "Error: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	// Show which directory the tools will touch so running in the wrong one is noticed.
+	if root, err := filepath.Abs(tools.RootDir); err == nil {
+		config.PromptPrefix = filepath.Base(root)
+	}
+	config.MaxTurns = *maxTurns
+	config.Prompt = prompt
+	config.Verbose = *verbose
+	config.Quiet = *quiet
+	config.SessionFile = *sessionFile
+	config.AutoApprove = *yes
+	config.Output = out
+	if *watch {
+		config.Watcher = tools.NewStatWatcher()
+	}
+	if *transactional || file.Transactional {
+		config.Transaction = tools.DefaultJournal
+	}
+	var stats *agent.ToolStats
+	if *toolStats {
+		stats = agent.NewToolStats()
+		config.Metrics = stats
+	}
+	provider, err := agent.NewProvider(config)
+	if err != nil {
+		fmt.Fprintf(out, "Error: %s\n", err)
+		os.Exit(1)
+	}
+	ag, err := agent.NewAgent(provider, getUserMessage, selectedTools, config)
+	if err != nil {
+		fmt.Fprintf(out, "Error: %s\n", err)
+		os.Exit(1)
 	}
 
-	ag := agent.NewAgent(client, getUserMessage, toolsList)
-	if err := ag.Run(context.TODO()); err != nil {
-		fmt.Printf("Error: %s\n", err)
+	// In an interactive session ctrl-c interrupts Claude's current turn and returns to the prompt.
+	// Otherwise it cancels the running request and ends the session cleanly. Once that has happened
+	// the default handler is restored, so a further ctrl-c force-quits.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for sig := range sigs {
+			if sig == os.Interrupt && ag.Interrupt() {
+				continue
+			}
+			signal.Stop(sigs)
+			cancel()
+			return
+		}
+	}()
+
+	err = ag.Run(ctx)
+	if stats != nil {
+		fmt.Fprintln(out, "Tool calls:")
+		stats.WriteReport(out)
+	}
+	timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded)
+	if timedOut {
+		err = fmt.Errorf("session timed out after %s", *timeout)
+	}
+	if *output == "json" {
+		writeJSONResult(os.Stdout, ag, err)
+	}
+	if timedOut {
+		fmt.Fprintf(out, "\nError: %s\n", err)
+		os.Exit(124)
+	}
+	if ctx.Err() != nil {
+		fmt.Fprintln(out)
+		os.Exit(130)
+	}
+	if err != nil {
+		fmt.Fprintf(out, "Error: %s\n", err)
+	}
+}
+
+// readContextFiles formats the files at paths as a message with a header per file.
+// Their total size may not exceed limit bytes, the largest tool result the model is given.
+func readContextFiles(paths []string, limit int) (string, error) {
+	var sb strings.Builder
+	sb.WriteString("I'm attaching these files for context:\n")
+	total := 0
+	for _, p := range paths {
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return "", fmt.Errorf("failed to read context file: %w", err)
+		}
+		if bytes.IndexByte(content, 0) != -1 {
+			return "", fmt.Errorf("context file %s is binary", p)
+		}
+		total += len(content)
+		if total > limit {
+			return "", fmt.Errorf("context files exceed %d bytes at %s; pass fewer or smaller files, or raise max_tool_output", limit, p)
+		}
+		fmt.Fprintf(&sb, "\n==> %s <==\n%s", p, content)
+		if len(content) > 0 && content[len(content)-1] != '\n' {
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String(), nil
+}
+
+// versionString describes the build: its version, commit, and Go version.
+// Without a stamped commit it falls back to the VCS revision Go embeds when building from a checkout.
+func versionString() string {
+	rev := commit
+	if info, ok := debug.ReadBuildInfo(); ok && rev == "" {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				rev = setting.Value
+				if len(rev) > 12 {
+					rev = rev[:12]
+				}
+			}
+		}
+	}
+	if rev == "" {
+		rev = "unknown"
+	}
+	return fmt.Sprintf("oen %s (commit %s, %s)", version, rev, runtime.Version())
+}
+
+// newToolRunner returns an agent that runs the tools for "oen serve" with the configured policy,
+// timeouts, and limits. Stdout carries the protocol, so it prints to stderr, and since nobody can
+// be asked there, tools that need confirmation are declined unless autoApprove is set.
+func newToolRunner(file *config.File, flags config.Flags, selected []agent.ToolDefinition, autoApprove bool) (*agent.Agent, error) {
+	cfg, err := file.AgentConfig(flags)
+	if err != nil {
+		return nil, err
+	}
+	cfg.AutoApprove = autoApprove
+	cfg.Quiet = true
+	cfg.Output = os.Stderr
+	noUser := func() (string, bool) { return "", false }
+	return agent.NewAgent(nil, noUser, selected, cfg)
+}
+
+// loadConfigFile loads the config file at path, or the first one found in the default locations,
+// and returns it with its path. Without a config file it returns an empty one and no path.
+func loadConfigFile(path string) (*config.File, string, error) {
+	if path == "" {
+		found, err := config.Find()
+		if err != nil || found == "" {
+			return &config.File{}, "", err
+		}
+		path = found
+	}
+	file, err := config.Load(path)
+	return file, path, err
+}
+
+// jsonResult is the output of a non-interactive run with --output json
+type jsonResult struct {
+	agent.TurnResult
+	Usage        agent.Usage `json:"usage"`
+	ChangedFiles []string    `json:"changed_files"`
+	Error        string      `json:"error,omitempty"`
+}
+
+// writeJSONResult writes the outcome of the run to w as a single JSON object
+func writeJSONResult(w io.Writer, ag *agent.Agent, runErr error) {
+	result := jsonResult{
+		TurnResult:   ag.LastTurn(),
+		Usage:        ag.Usage(),
+		ChangedFiles: ag.ChangedFiles(),
+	}
+	if runErr != nil {
+		result.Error = runErr.Error()
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 	}
 }
@@ -4,7 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
+	"github.com/MarkusZoppelt/oen/pkg/session"
 	anthropic "github.com/anthropics/anthropic-sdk-go"
 )
 
@@ -13,117 +21,608 @@ type ToolDefinition struct {
 	Name        string
 	Description string
 	InputSchema anthropic.ToolInputSchemaParam
-	Function    func(input json.RawMessage) (string, error)
+	// Function runs the tool. It should return early with ctx.Err() once ctx is done, which happens
+	// when the tool times out or the session is canceled.
+	Function func(ctx context.Context, input json.RawMessage) (string, error)
+	// RequiresConfirmation asks the user before running the tool unless auto-approve is set
+	RequiresConfirmation bool
+	// Timeout, if set, overrides Config.ToolTimeout for this tool
+	Timeout time.Duration
+	// ChangedPaths, if set, returns the paths a successful call wrote to so the agent can report them
+	ChangedPaths func(input json.RawMessage, output string) []string
+	// ReadOnly marks tools without side effects. Consecutive calls to such tools in one response run concurrently.
+	ReadOnly bool
+	// Category groups tools for Config.Policy, e.g. CategoryDelete. If empty, it is CategoryRead
+	// for read-only tools and CategoryEdit for all others.
+	Category string
+}
+
+// InputSchemaMap returns the tool's input schema as a plain JSON object for clients other than the Anthropic API
+func (t ToolDefinition) InputSchemaMap() (map[string]any, error) {
+	data, err := json.Marshal(t.InputSchema)
+	if err != nil {
+		return nil, err
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, err
+	}
+	// The SDK emits its ExtraFields placeholder as a "-" key, which isn't part of the schema.
+	delete(schema, "-")
+	return schema, nil
 }
 
-// Agent orchestrates the conversation and tool execution
 // Agent orchestrates the conversation and tool execution
 type Agent struct {
-   client         anthropic.Client
+	provider       Provider
 	getUserMessage func() (string, bool)
 	tools          []ToolDefinition
+	schemas        map[string]*jsonSchema
+	config         Config
+	usage          Usage
+	changed        map[string]bool
+	lastTurn       TurnResult
+	out            io.Writer
+	// turnUsage is the part of usage spent answering the current user message
+	turnUsage Usage
+	// redactor masks secrets in tool output, or is nil if Config.RedactSecrets is off
+	redactor *redactor
+	// compactLevel is the number of compactionTiers whose threshold the conversation had crossed when it
+	// was last compacted, so that a tier only fires again after the conversation has dropped below it
+	compactLevel int
+
+	turnMu sync.Mutex
+	// cancelTurn cancels the model's current turn, if one is running
+	cancelTurn context.CancelFunc
 }
 
-// NewAgent creates a new Agent with given client, input function, and tools
-// NewAgent creates a new Agent with given client, input function, and tools
+// NewAgent creates a new Agent with given provider, input function, tools, and config
 func NewAgent(
-   client anthropic.Client,
+	provider Provider,
 	getUserMessage func() (string, bool),
 	tools []ToolDefinition,
-) *Agent {
+	config Config,
+) (*Agent, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	if err := checkDuplicateTools(tools); err != nil {
+		return nil, err
+	}
+	schemas := map[string]*jsonSchema{}
+	for _, tool := range tools {
+		schema, err := parseSchema(tool.InputSchema)
+		if err != nil {
+			return nil, fmt.Errorf("invalid input schema for tool %s: %w", tool.Name, err)
+		}
+		schemas[tool.Name] = schema
+	}
+	var redactor *redactor
+	if config.RedactSecrets {
+		var err error
+		if redactor, err = newRedactor(config.RedactPatterns); err != nil {
+			return nil, err
+		}
+	}
 	return &Agent{
-		client:         client,
+		provider:       provider,
 		getUserMessage: getUserMessage,
 		tools:          tools,
+		schemas:        schemas,
+		config:         config,
+		changed:        map[string]bool{},
+		lastTurn:       TurnResult{ToolCalls: []ToolCall{}},
+		out:            config.Output,
+		redactor:       redactor,
+	}, nil
+}
+
+// checkDuplicateTools returns an error naming every tool name that is defined more than once
+func checkDuplicateTools(tools []ToolDefinition) error {
+	seen := map[string]int{}
+	var duplicates []string
+	for _, tool := range tools {
+		seen[tool.Name]++
+		if seen[tool.Name] == 2 {
+			duplicates = append(duplicates, tool.Name)
+		}
+	}
+	if len(duplicates) > 0 {
+		return fmt.Errorf("duplicate tool names: %s", strings.Join(duplicates, ", "))
 	}
+	return nil
 }
 
 // Run starts the interactive CLI session
 func (a *Agent) Run(ctx context.Context) error {
 	conversation := []anthropic.MessageParam{}
+	if a.config.SessionFile != "" {
+		history, err := session.Load(a.config.SessionFile)
+		if err != nil {
+			return err
+		}
+		conversation = append(conversation, history...)
+	}
 
-	fmt.Println("Chat with Claude (use 'ctrl-c' to quit)")
+	interactive := a.config.Prompt == ""
+	if interactive {
+		fmt.Fprintln(a.out, "Chat with Claude (use 'ctrl-c' to interrupt Claude or to quit, '/help' for commands)")
+		if len(conversation) > 0 {
+			fmt.Fprintf(a.out, "Resumed session with %d messages from %s\n", len(conversation), a.config.SessionFile)
+		}
+	}
+	if interactive || a.config.Verbose {
+		defer func() {
+			fmt.Fprintf(a.out, "\nSession usage: %s\n", a.usage)
+		}()
+	}
+	defer func() {
+		if changed := a.ChangedFiles(); len(changed) > 0 && !a.config.Quiet {
+			fmt.Fprintf(a.out, "Changed files:\n  %s\n", strings.Join(changed, "\n  "))
+		}
+	}()
 
-	readUserInput := true
+	// A session saved right after tool results still owes the model a reply.
+	readUserInput := len(conversation) == 0 || conversation[len(conversation)-1].Role == anthropic.MessageParamRoleAssistant
+	promptSent := false
+	contextSent := false
+	turns := 0
+	// turnCtx is canceled by Interrupt and is nil while waiting for the user
+	var turnCtx context.Context
 	for {
 		if readUserInput {
-			fmt.Print("\u001b[94mYou\u001b[0m: ")
-			userInput, ok := a.getUserMessage()
-			if !ok {
-				break
+			a.endTurn()
+			turnCtx = nil
+			if a.config.Watcher != nil {
+				// Forget the changes made during the last turn, which the model already knows about.
+				a.config.Watcher.Changes()
+			}
+			var userInput string
+			if interactive {
+				if a.config.PromptPrefix != "" {
+					fmt.Fprint(a.out, colorize(colorGray, a.config.PromptPrefix)+" ")
+				}
+				fmt.Fprint(a.out, colorize(colorBlue, "You")+": ")
+				var ok bool
+				userInput, ok = a.getUserMessage()
+				if !ok {
+					break
+				}
+				if updated, handled := a.runCommand(ctx, userInput, conversation); handled {
+					conversation = updated
+					if err := a.saveSession(conversation); err != nil {
+						return err
+					}
+					continue
+				}
+			} else {
+				// In single-prompt mode the run ends once the model stops using tools.
+				if promptSent {
+					break
+				}
+				userInput, promptSent = a.config.Prompt, true
 			}
 
-			userMessage := anthropic.NewUserMessage(anthropic.NewTextBlock(userInput))
+			blocks := []anthropic.ContentBlockParamUnion{anthropic.NewTextBlock(userInput)}
+			if note := a.externalChanges(); note != "" {
+				blocks = append([]anthropic.ContentBlockParamUnion{anthropic.NewTextBlock(note)}, blocks...)
+			}
+			if !contextSent && a.config.InitialContext != "" {
+				blocks = append([]anthropic.ContentBlockParamUnion{anthropic.NewTextBlock(a.config.InitialContext)}, blocks...)
+				contextSent = true
+			}
+			userMessage := anthropic.NewUserMessage(blocks...)
 			conversation = append(conversation, userMessage)
+			turns = 0
+			a.lastTurn = TurnResult{ToolCalls: []ToolCall{}}
+			a.turnUsage = Usage{}
+		}
+
+		if turnCtx == nil {
+			turnCtx = a.startTurn(ctx, interactive)
+		}
+		turns++
+		if turns > a.config.MaxTurns {
+			return fmt.Errorf("stopped after %d model turns without a final answer, the model may be stuck in a tool loop", a.config.MaxTurns)
 		}
 
-		message, err := a.runInference(ctx, conversation)
+		var err error
+		var message *anthropic.Message
+		conversation, err = a.fitContext(turnCtx, conversation)
+		if err == nil {
+			message, err = a.runInference(turnCtx, conversation)
+		}
 		if err != nil {
+			if ctx.Err() == nil && turnCtx.Err() != nil {
+				// Only this turn was interrupted. Close it so the conversation stays valid and the model knows.
+				fmt.Fprintln(a.out, "\n"+colorize(colorGray, "Interrupted"))
+				conversation = append(conversation, anthropic.NewAssistantMessage(anthropic.NewTextBlock(interruptedText)))
+				if err := a.saveSession(conversation); err != nil {
+					return err
+				}
+				readUserInput = true
+				continue
+			}
+			if ctx.Err() != nil {
+				// Keep the unanswered message so a resumed session picks up where it was interrupted.
+				if err := a.saveSession(conversation); err != nil {
+					return err
+				}
+			}
 			return err
 		}
-		conversation = append(conversation, message.ToParam())
+		conversation = append(conversation, messageParam(message))
+		a.lastTurn.Text = messageText(message)
+		a.turnUsage.add(a.recordUsage(message.Usage))
+
+		toolResults := a.executeTools(turnCtx, message.Content)
+		readUserInput = len(toolResults) == 0
+		if readUserInput && a.config.Verbose {
+			fmt.Fprintln(a.out, colorize(colorGray, fmt.Sprintf("turn: %s | session: ~$%.4f", a.turnUsage, a.usage.Cost)))
+		}
+		if !readUserInput {
+			conversation = append(conversation, anthropic.NewUserMessage(toolResults...))
+		}
+
+		if err := a.saveSession(conversation); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// externalChanges describes the files the watcher saw change since the last turn, or returns "" if there are none
+func (a *Agent) externalChanges() string {
+	if a.config.Watcher == nil {
+		return ""
+	}
+	changed, err := a.config.Watcher.Changes()
+	if err != nil || len(changed) == 0 {
+		return ""
+	}
+	a.notef("%d files changed since the last turn", len(changed))
+	return "Note: these files were changed outside of this conversation since the last message, re-read them before relying on their content:\n" + strings.Join(changed, "\n")
+}
+
+// notef prints a status note, e.g. about the context window, unless Config.Quiet is set
+func (a *Agent) notef(format string, args ...any) {
+	fmt.Fprintln(a.config.notes(), colorize(colorGray, fmt.Sprintf(format, args...)))
+}
 
-		toolResults := []anthropic.ContentBlockParamUnion{}
-		for _, content := range message.Content {
-			switch content.Type {
-			case "text":
-				fmt.Printf("\u001b[93mClaude\u001b[0m: %s\n", content.Text)
-			case "tool_use":
-				result := a.executeTool(content.ID, content.Name, content.Input)
-				toolResults = append(toolResults, result)
+// interruptedText stands in for the model's reply to a turn the user interrupted
+const interruptedText = "[The user interrupted this response.]"
+
+// startTurn returns the context for a model turn. In interactive mode Interrupt can cancel it.
+func (a *Agent) startTurn(ctx context.Context, interactive bool) context.Context {
+	if !interactive {
+		return ctx
+	}
+	turnCtx, cancel := context.WithCancel(ctx)
+	a.turnMu.Lock()
+	defer a.turnMu.Unlock()
+	a.cancelTurn = cancel
+	return turnCtx
+}
+
+// endTurn releases the context of the current turn, if any
+func (a *Agent) endTurn() {
+	a.turnMu.Lock()
+	defer a.turnMu.Unlock()
+	if a.cancelTurn != nil {
+		a.cancelTurn()
+		a.cancelTurn = nil
+	}
+}
+
+// Interrupt cancels the model's current turn in an interactive session and reports whether one was running.
+// The session then waits for the next user message. It is safe to call from another goroutine, e.g. a signal handler.
+func (a *Agent) Interrupt() bool {
+	a.turnMu.Lock()
+	defer a.turnMu.Unlock()
+	if a.cancelTurn == nil {
+		return false
+	}
+	a.cancelTurn()
+	a.cancelTurn = nil
+	return true
+}
+
+// saveSession writes the conversation to the session file, if one is configured
+func (a *Agent) saveSession(conversation []anthropic.MessageParam) error {
+	if a.config.SessionFile == "" {
+		return nil
+	}
+	return session.Save(a.config.SessionFile, conversation)
+}
+
+// maxParallelTools caps how many read-only tool calls run at the same time
+const maxParallelTools = 4
+
+// executeTools runs the tool calls in a response and returns their results in the same order.
+// Runs of consecutive read-only calls execute concurrently; every other call runs on its own,
+// so a read that follows a write in the response still sees the write.
+func (a *Agent) executeTools(ctx context.Context, content []anthropic.ContentBlockUnion) []anthropic.ContentBlockParamUnion {
+	var uses []anthropic.ContentBlockUnion
+	for _, block := range content {
+		if block.Type == "tool_use" {
+			uses = append(uses, block)
+		}
+	}
+	results := make([]anthropic.ContentBlockParamUnion, len(uses))
+	calls := make([]ToolCall, len(uses))
+	if a.config.Transaction != nil {
+		a.config.Transaction.Begin()
+	}
+	// failed is the first tool changing files to fail, after which such tools are skipped in a transaction
+	failed := ""
+	for i := 0; i < len(uses); {
+		j := i + 1
+		if a.runsConcurrently(uses[i].Name) {
+			for j < len(uses) && a.runsConcurrently(uses[j].Name) {
+				j++
 			}
 		}
-		if len(toolResults) == 0 {
-			readUserInput = true
+		if j-i == 1 {
+			if a.config.Transaction != nil && failed != "" && a.changesFiles(uses[i].Name) {
+				calls[i] = ToolCall{Name: uses[i].Name, Input: uses[i].Input, IsError: true, ErrorCode: ToolErrorRolledBack,
+					Output: fmt.Sprintf("not run because %s failed earlier in this response, whose file edits are rolled back", failed)}
+				results[i] = anthropic.NewToolResultBlock(uses[i].ID, calls[i].Output, true)
+			} else {
+				results[i], calls[i] = a.executeTool(ctx, uses[i].ID, uses[i].Name, uses[i].Input)
+				if calls[i].IsError && failed == "" && a.changesFiles(uses[i].Name) {
+					failed = uses[i].Name
+				}
+			}
+			i = j
 			continue
 		}
-		readUserInput = false
-		conversation = append(conversation, anthropic.NewUserMessage(toolResults...))
+
+		var wg sync.WaitGroup
+		slots := make(chan struct{}, maxParallelTools)
+		for k := i; k < j; k++ {
+			wg.Add(1)
+			slots <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-slots }()
+				results[k], calls[k] = a.executeTool(ctx, uses[k].ID, uses[k].Name, uses[k].Input)
+			}()
+		}
+		wg.Wait()
+		i = j
+	}
+	if a.config.Transaction != nil {
+		if failed == "" {
+			a.config.Transaction.Commit()
+		} else {
+			a.rollback(uses, results, calls, failed)
+		}
 	}
+	a.lastTurn.ToolCalls = append(a.lastTurn.ToolCalls, calls...)
+	return results
+}
 
-	return nil
+// rollback reverts the file changes of a response in which the tool failed, and marks the calls
+// that made them as failed so that the model doesn't rely on their results
+func (a *Agent) rollback(uses []anthropic.ContentBlockUnion, results []anthropic.ContentBlockParamUnion, calls []ToolCall, failed string) {
+	restored, err := a.config.Transaction.Rollback()
+	if err != nil {
+		fmt.Fprintln(a.out, colorize(colorRed, "Error")+": failed to roll back: "+err.Error())
+	}
+	if len(restored) == 0 && err == nil {
+		return
+	}
+	a.notef("%s failed, rolled back the changes to %s", failed, strings.Join(restored, ", "))
+	for i, use := range uses {
+		if calls[i].IsError || !a.changesFiles(use.Name) {
+			continue
+		}
+		calls[i].IsError, calls[i].ErrorCode = true, ToolErrorRolledBack
+		if err != nil {
+			calls[i].Output = fmt.Sprintf("%s failed later in this response, but rolling back its file edits failed (%s), so files may be partly changed; check them. This call's result was: %s", failed, err, calls[i].Output)
+		} else {
+			calls[i].Output = fmt.Sprintf("rolled back because %s failed later in this response; the response's file edits were undone, restoring %s. This call's result was: %s", failed, strings.Join(restored, ", "), calls[i].Output)
+		}
+		results[i] = anthropic.NewToolResultBlock(use.ID, calls[i].Output, true)
+	}
 }
 
-// executeTool executes a tool by name with given input
-func (a *Agent) executeTool(id, name string, input json.RawMessage) anthropic.ContentBlockParamUnion {
+// changesFiles reports whether name is a known tool in CategoryEdit or CategoryDelete. Commands are left
+// out of transactions, as they fail for many reasons that don't call for reverting edits.
+func (a *Agent) changesFiles(name string) bool {
+	for _, tool := range a.tools {
+		if tool.Name == name {
+			category := tool.category()
+			return category == CategoryEdit || category == CategoryDelete
+		}
+	}
+	return false
+}
+
+// runsConcurrently reports whether name is a known read-only tool. Tools that ask for confirmation
+// never run concurrently so that their prompts can't interleave.
+func (a *Agent) runsConcurrently(name string) bool {
+	for _, tool := range a.tools {
+		if tool.Name == name {
+			return tool.ReadOnly && !a.config.needsConfirmation(tool)
+		}
+	}
+	return false
+}
+
+// executeTool executes a tool by name with given input and returns the result block along with the call for LastTurn
+func (a *Agent) executeTool(ctx context.Context, id, name string, input json.RawMessage) (anthropic.ContentBlockParamUnion, ToolCall) {
+	output, err := a.runTool(ctx, name, input)
+	call := ToolCall{Name: name, Input: input, Output: a.limitOutput(output)}
+	if err != nil {
+		// Errors can carry long output too, e.g. a failed command's.
+		call.Output, call.IsError, call.ErrorCode = a.limitOutput(err.Error()), true, ErrorCode(err)
+	}
+	return anthropic.NewToolResultBlock(id, call.Output, call.IsError), call
+}
+
+// CallTool runs a single tool call outside of a conversation, e.g. for an MCP client, the same way
+// calls from the model are run: the input is validated, the policy applied, and the output limited and redacted
+func (a *Agent) CallTool(ctx context.Context, name string, input json.RawMessage) ToolCall {
+	_, call := a.executeTool(ctx, "", name, input)
+	return call
+}
+
+// runTool validates the input, asks for confirmation if needed, and runs the tool.
+// Once ctx is done, tools are no longer run.
+func (a *Agent) runTool(ctx context.Context, name string, input json.RawMessage) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", fmt.Errorf("tool not run: %w", err)
+	}
 	var toolDef ToolDefinition
 	var found bool
 	for _, tool := range a.tools {
 		if tool.Name == name {
-      toolDef = tool
+			toolDef = tool
 			found = true
 			break
 		}
 	}
 	if !found {
-		return anthropic.NewToolResultBlock(id, "tool not found", true)
+		return "", NewToolError(ToolErrorUnknownTool, "tool not found")
+	}
+	if err := validateInput(a.schemas[name], input); err != nil {
+		return "", &ToolError{Code: ToolErrorInvalidInput, Err: err}
 	}
 
-	fmt.Printf("\u001b[92mtool\u001b[0m: %s(%s)\n", name, input)
-	response, err := toolDef.Function(input)
+	if a.config.policyFor(toolDef) == PolicyDeny {
+		return "", NewToolError(ToolErrorPolicyDenied, "%s is denied by policy", name)
+	}
+	if a.config.needsConfirmation(toolDef) && !a.confirm(name, input) {
+		return "", NewToolError(ToolErrorDeclined, "the user declined to run this tool")
+	}
+
+	if !a.config.Quiet {
+		fmt.Fprintf(a.out, "%s: %s(%s)\n", colorize(colorGreen, "tool"), name, input)
+	}
+	start := time.Now()
+	response, err := a.callTool(ctx, toolDef, input)
+	response, err = a.redact(response, err)
+	if err != nil && a.config.Quiet {
+		fmt.Fprintf(a.out, "%s: %s(%s) failed: %s\n", colorize(colorRed, "tool"), name, input, err)
+	}
+	duration := time.Since(start)
+	a.logToolCall(name, input, response, err, duration)
+	if a.config.Metrics != nil {
+		a.config.Metrics.RecordToolCall(name, duration, err)
+	}
+	if err != nil {
+		return "", err
+	}
+	if toolDef.ChangedPaths != nil {
+		for _, p := range toolDef.ChangedPaths(input, response) {
+			a.changed[p] = true
+		}
+	}
+	return response, nil
+}
+
+// limitOutput truncates a tool result to Config.MaxToolOutput bytes so that no single tool can overflow the context
+func (a *Agent) limitOutput(output string) string {
+	if len(output) <= a.config.MaxToolOutput {
+		return output
+	}
+	cut := a.config.MaxToolOutput
+	// Don't split a multi-byte character.
+	for cut > 0 && !utf8.RuneStart(output[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s\n... [truncated: showing the first %d of %d bytes. Request less at once, e.g. with read_file_lines, tail_file, or a narrower path or pattern.]",
+		output[:cut], cut, len(output))
+}
+
+// ChangedFiles returns the sorted paths that tools have written to so far in the session
+func (a *Agent) ChangedFiles() []string {
+	changed := make([]string, 0, len(a.changed))
+	for p := range a.changed {
+		changed = append(changed, p)
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// callTool runs the tool's function, giving up once its timeout has passed or ctx is done.
+// The function's context is canceled then too; a function that ignores it keeps running in
+// the background, but the conversation can continue.
+func (a *Agent) callTool(ctx context.Context, tool ToolDefinition, input json.RawMessage) (string, error) {
+	timeout := a.config.ToolTimeout
+	if tool.Timeout > 0 {
+		timeout = tool.Timeout
+	}
+	toolCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		output string
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		output, err := tool.Function(toolCtx, input)
+		done <- result{output, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.output, r.err
+	case <-timer.C:
+		return "", NewToolError(ToolErrorTimeout, "tool %s timed out after %s", tool.Name, timeout)
+	case <-ctx.Done():
+		return "", fmt.Errorf("tool %s canceled: %w", tool.Name, ctx.Err())
+	}
+}
+
+// logToolCall writes a structured record of a tool call to the tool log, if one is configured
+func (a *Agent) logToolCall(name string, input json.RawMessage, output string, err error, duration time.Duration) {
+	if a.config.ToolLogger == nil {
+		return
+	}
+	errMsg := ""
 	if err != nil {
-		return anthropic.NewToolResultBlock(id, err.Error(), true)
+		errMsg = err.Error()
+	}
+	a.config.ToolLogger.Info("tool_call",
+		slog.String("tool", name),
+		slog.Any("input", input),
+		slog.String("output", output),
+		slog.String("error", errMsg),
+		slog.String("error_code", string(ErrorCode(err))),
+		slog.Int64("duration_ms", duration.Milliseconds()),
+	)
+}
+
+// confirm asks the user whether a tool may run and reports their answer
+func (a *Agent) confirm(name string, input json.RawMessage) bool {
+	fmt.Fprintf(a.out, "%s: run %s(%s)? [y/N] ", colorize(colorRed, "confirm"), name, input)
+	answer, ok := a.getUserMessage()
+	if !ok {
+		return false
 	}
-	return anthropic.NewToolResultBlock(id, response, false)
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
 }
 
-// runInference sends messages to the AI model and returns the AI response
+// runInference sends the conversation and available tools to the provider and returns the AI response
 func (a *Agent) runInference(ctx context.Context, conversation []anthropic.MessageParam) (*anthropic.Message, error) {
-	anthropicTools := []anthropic.ToolUnionParam{}
-	for _, tool := range a.tools {
-		anthropicTools = append(anthropicTools, anthropic.ToolUnionParam{
-			OfTool: &anthropic.ToolParam{
-				Name:        tool.Name,
-				Description: anthropic.String(tool.Description),
-				InputSchema: tool.InputSchema,
-			},
-		})
-	}
-
-	return a.client.Messages.New(ctx, anthropic.MessageNewParams{
-		Model:     anthropic.ModelClaude3_7SonnetLatest,
-		MaxTokens: int64(1000),
-		Messages:  conversation,
-		Tools:     anthropicTools,
-	})
+	return a.provider.Infer(ctx, a.inferenceRequest(conversation))
+}
+
+// inferenceRequest builds a provider request for the conversation from the agent's config and tools
+func (a *Agent) inferenceRequest(conversation []anthropic.MessageParam) InferenceRequest {
+	return InferenceRequest{
+		Model:          a.config.Model,
+		MaxTokens:      a.config.MaxTokens,
+		SystemPrompt:   a.config.SystemPrompt,
+		ThinkingBudget: a.config.ThinkingBudget,
+		Conversation:   conversation,
+		Tools:          a.tools,
+	}
 }
@@ -0,0 +1,358 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	anthropic "github.com/anthropics/anthropic-sdk-go"
+)
+
+// fakeProvider answers with scripted responses, one per request, and keeps the requests it got
+type fakeProvider struct {
+	mu        sync.Mutex
+	responses []fakeResponse
+	requests  []InferenceRequest
+}
+
+// fakeResponse is a scripted model response, or the error to fail the request with
+type fakeResponse struct {
+	message *anthropic.Message
+	err     error
+}
+
+func (p *fakeProvider) Infer(ctx context.Context, req InferenceRequest) (*anthropic.Message, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests = append(p.requests, req)
+	if len(p.responses) == 0 {
+		return nil, errors.New("no scripted response left")
+	}
+	r := p.responses[0]
+	p.responses = p.responses[1:]
+	return r.message, r.err
+}
+
+// fakeBlock is a content block of a scripted response
+type fakeBlock map[string]any
+
+// textBlock returns a text block for a scripted response
+func textBlock(text string) fakeBlock {
+	return fakeBlock{"type": "text", "text": text}
+}
+
+// toolUseBlock returns a tool_use block for a scripted response
+func toolUseBlock(id, name, input string) fakeBlock {
+	return fakeBlock{"type": "tool_use", "id": id, "name": name, "input": json.RawMessage(input)}
+}
+
+// response builds a scripted model response, going through JSON like the providers do
+func response(t *testing.T, stopReason string, blocks ...fakeBlock) fakeResponse {
+	t.Helper()
+	data, err := json.Marshal(map[string]any{
+		"id":          "msg",
+		"type":        "message",
+		"role":        "assistant",
+		"model":       DefaultModel,
+		"content":     blocks,
+		"stop_reason": stopReason,
+		"usage":       map[string]int64{"input_tokens": 1, "output_tokens": 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var message anthropic.Message
+	if err := json.Unmarshal(data, &message); err != nil {
+		t.Fatal(err)
+	}
+	return fakeResponse{message: &message}
+}
+
+// toolResult is a tool_result block as sent back to the model
+type toolResult struct {
+	ToolUseID string `json:"tool_use_id"`
+	IsError   bool   `json:"is_error"`
+	Content   []struct {
+		Text string `json:"text"`
+	} `json:"content"`
+}
+
+// toolResults returns the tool_result blocks of the last message of a request
+func toolResults(t *testing.T, req InferenceRequest) []toolResult {
+	t.Helper()
+	last := req.Conversation[len(req.Conversation)-1]
+	var results []toolResult
+	for _, block := range last.Content {
+		if block.OfRequestToolResultBlock == nil {
+			continue
+		}
+		data, err := json.Marshal(block.OfRequestToolResultBlock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result toolResult
+		if err := json.Unmarshal(data, &result); err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// testTools returns an echo tool that requires a text input and a tool that always fails
+func testTools() []ToolDefinition {
+	echoSchema := anthropic.ToolInputSchemaParam{Properties: map[string]any{"text": map[string]any{"type": "string"}}}
+	echoSchema.WithExtraFields(map[string]any{"required": []string{"text"}})
+	return []ToolDefinition{
+		{
+			Name:        "echo",
+			InputSchema: echoSchema,
+			Function: func(ctx context.Context, input json.RawMessage) (string, error) {
+				var in struct{ Text string }
+				if err := json.Unmarshal(input, &in); err != nil {
+					return "", err
+				}
+				return "echo: " + in.Text, nil
+			},
+			ReadOnly: true,
+		},
+		{
+			Name:        "fail",
+			InputSchema: anthropic.ToolInputSchemaParam{Properties: map[string]any{}},
+			Function: func(ctx context.Context, input json.RawMessage) (string, error) {
+				return "", NewToolError(ToolErrorNotFound, "nothing here")
+			},
+		},
+	}
+}
+
+func TestRunToolLoop(t *testing.T) {
+	// wantResult is the expected tool_result for a tool_use, in the order of the response
+	type wantResult struct {
+		id      string
+		text    string
+		isError bool
+	}
+	tests := []struct {
+		name      string
+		responses func(t *testing.T) []fakeResponse
+		maxTurns  int
+		// wantResults holds the expected tool results sent with each request after the first
+		wantResults [][]wantResult
+		wantCodes   []ToolErrorCode
+		wantText    string
+		wantErr     string
+	}{
+		{
+			name: "answer without tools",
+			responses: func(t *testing.T) []fakeResponse {
+				return []fakeResponse{response(t, "end_turn", textBlock("done"))}
+			},
+			wantText: "done",
+		},
+		{
+			name: "one tool",
+			responses: func(t *testing.T) []fakeResponse {
+				return []fakeResponse{
+					response(t, "tool_use", textBlock("let me check"), toolUseBlock("t1", "echo", `{"text":"hi"}`)),
+					response(t, "end_turn", textBlock("done")),
+				}
+			},
+			wantResults: [][]wantResult{{{id: "t1", text: "echo: hi"}}},
+			wantCodes:   []ToolErrorCode{""},
+			wantText:    "done",
+		},
+		{
+			name: "results paired with their calls",
+			responses: func(t *testing.T) []fakeResponse {
+				return []fakeResponse{
+					response(t, "tool_use",
+						toolUseBlock("t1", "echo", `{"text":"a"}`),
+						toolUseBlock("t2", "echo", `{"text":"b"}`),
+						toolUseBlock("t3", "echo", `{"text":"c"}`)),
+					response(t, "end_turn", textBlock("done")),
+				}
+			},
+			wantResults: [][]wantResult{{{id: "t1", text: "echo: a"}, {id: "t2", text: "echo: b"}, {id: "t3", text: "echo: c"}}},
+			wantCodes:   []ToolErrorCode{"", "", ""},
+			wantText:    "done",
+		},
+		{
+			name: "tools over several turns",
+			responses: func(t *testing.T) []fakeResponse {
+				return []fakeResponse{
+					response(t, "tool_use", toolUseBlock("t1", "echo", `{"text":"a"}`)),
+					response(t, "tool_use", toolUseBlock("t2", "echo", `{"text":"b"}`)),
+					response(t, "end_turn", textBlock("done")),
+				}
+			},
+			wantResults: [][]wantResult{{{id: "t1", text: "echo: a"}}, {{id: "t2", text: "echo: b"}}},
+			wantCodes:   []ToolErrorCode{"", ""},
+			wantText:    "done",
+		},
+		{
+			name: "errors reported to the model",
+			responses: func(t *testing.T) []fakeResponse {
+				return []fakeResponse{
+					response(t, "tool_use",
+						toolUseBlock("t1", "fail", `{}`),
+						toolUseBlock("t2", "missing", `{}`),
+						toolUseBlock("t3", "echo", `{}`),
+						toolUseBlock("t4", "echo", `{"text":"ok"}`)),
+					response(t, "end_turn", textBlock("done")),
+				}
+			},
+			wantResults: [][]wantResult{{
+				{id: "t1", text: "nothing here", isError: true},
+				{id: "t2", text: "tool not found", isError: true},
+				{id: "t3", text: "text", isError: true},
+				{id: "t4", text: "echo: ok"},
+			}},
+			wantCodes: []ToolErrorCode{ToolErrorNotFound, ToolErrorUnknownTool, ToolErrorInvalidInput, ""},
+			wantText:  "done",
+		},
+		{
+			name: "stops at max tokens without tools",
+			responses: func(t *testing.T) []fakeResponse {
+				return []fakeResponse{response(t, "max_tokens", textBlock("cut o"))}
+			},
+			wantText: "cut o",
+		},
+		{
+			name: "tool use is run whatever the stop reason",
+			responses: func(t *testing.T) []fakeResponse {
+				return []fakeResponse{
+					response(t, "max_tokens", toolUseBlock("t1", "echo", `{"text":"a"}`)),
+					response(t, "end_turn", textBlock("done")),
+				}
+			},
+			wantResults: [][]wantResult{{{id: "t1", text: "echo: a"}}},
+			wantCodes:   []ToolErrorCode{""},
+			wantText:    "done",
+		},
+		{
+			name: "stops after max turns",
+			responses: func(t *testing.T) []fakeResponse {
+				return []fakeResponse{
+					response(t, "tool_use", toolUseBlock("t1", "echo", `{"text":"a"}`)),
+					response(t, "tool_use", toolUseBlock("t2", "echo", `{"text":"b"}`)),
+				}
+			},
+			maxTurns:    2,
+			wantResults: [][]wantResult{{{id: "t1", text: "echo: a"}}},
+			wantCodes:   []ToolErrorCode{"", ""},
+			wantErr:     "stopped after 2 model turns",
+		},
+		{
+			name: "provider error",
+			responses: func(t *testing.T) []fakeResponse {
+				return []fakeResponse{
+					response(t, "tool_use", toolUseBlock("t1", "echo", `{"text":"a"}`)),
+					{err: errors.New("overloaded")},
+				}
+			},
+			wantResults: [][]wantResult{{{id: "t1", text: "echo: a"}}},
+			wantCodes:   []ToolErrorCode{""},
+			wantErr:     "overloaded",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &fakeProvider{responses: tt.responses(t)}
+			a, err := NewAgent(provider, nil, testTools(), Config{
+				Prompt:   "go",
+				MaxTurns: tt.maxTurns,
+				Quiet:    true,
+				Output:   io.Discard,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = a.Run(context.Background())
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("Run() = %v, want no error", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("Run() = %v, want an error containing %q", err, tt.wantErr)
+			}
+			if len(provider.responses) > 0 {
+				t.Errorf("%d scripted responses left unused", len(provider.responses))
+			}
+
+			for i, want := range tt.wantResults {
+				if i+1 >= len(provider.requests) {
+					t.Fatalf("got %d requests, want tool results in request %d", len(provider.requests), i+2)
+				}
+				got := toolResults(t, provider.requests[i+1])
+				if len(got) != len(want) {
+					t.Fatalf("request %d has %d tool results, want %d", i+2, len(got), len(want))
+				}
+				for j, w := range want {
+					text := ""
+					for _, c := range got[j].Content {
+						text += c.Text
+					}
+					if got[j].ToolUseID != w.id || got[j].IsError != w.isError || !strings.Contains(text, w.text) {
+						t.Errorf("request %d result %d = %s (error %v) %q, want %s (error %v) containing %q",
+							i+2, j, got[j].ToolUseID, got[j].IsError, text, w.id, w.isError, w.text)
+					}
+				}
+			}
+
+			calls := a.LastTurn().ToolCalls
+			codes := make([]ToolErrorCode, len(calls))
+			for i, call := range calls {
+				codes[i] = call.ErrorCode
+			}
+			if fmt.Sprint(codes) != fmt.Sprint(tt.wantCodes) {
+				t.Errorf("tool call error codes = %v, want %v", codes, tt.wantCodes)
+			}
+			if tt.wantErr == "" && a.LastTurn().Text != tt.wantText {
+				t.Errorf("LastTurn().Text = %q, want %q", a.LastTurn().Text, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestExecuteToolLimitsOutput(t *testing.T) {
+	long := strings.Repeat("x", 100)
+	tests := []struct {
+		name   string
+		output string
+		err    error
+	}{
+		{name: "output", output: long},
+		{name: "error", err: errors.New(long)},
+		{name: "tool error", err: NewToolError(ToolErrorFailed, "%s", long)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tools := []ToolDefinition{{
+				Name:        "long",
+				InputSchema: anthropic.ToolInputSchemaParam{Properties: map[string]any{}},
+				Function: func(ctx context.Context, input json.RawMessage) (string, error) {
+					return tt.output, tt.err
+				},
+			}}
+			a, err := NewAgent(&fakeProvider{}, nil, tools, Config{MaxToolOutput: 20, Quiet: true, Output: io.Discard})
+			if err != nil {
+				t.Fatal(err)
+			}
+			call := a.CallTool(context.Background(), "long", json.RawMessage(`{}`))
+			if call.IsError != (tt.err != nil) {
+				t.Errorf("IsError = %v, want %v", call.IsError, tt.err != nil)
+			}
+			if strings.Contains(call.Output, long) || !strings.Contains(call.Output, "truncated") {
+				t.Errorf("output = %q, want it truncated", call.Output)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	anthropic "github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+)
+
+// anthropicProvider talks to the Anthropic Messages API, streaming text to out as it arrives
+type anthropicProvider struct {
+	client     anthropic.Client
+	maxRetries int
+	// showThinking also streams extended thinking, which is hidden otherwise
+	showThinking bool
+	out          io.Writer
+	// notes receives the retry notices
+	notes io.Writer
+}
+
+// newAnthropicProvider returns a provider using ANTHROPIC_API_KEY from the environment that streams text to out
+// and prints retry notices to notes
+func newAnthropicProvider(maxRetries int, showThinking bool, out, notes io.Writer) *anthropicProvider {
+	return &anthropicProvider{
+		client:       anthropic.NewClient(),
+		maxRetries:   maxRetries,
+		showThinking: showThinking,
+		out:          out,
+		notes:        notes,
+	}
+}
+
+// Infer sends the conversation to the AI model, retrying transient API errors, and returns the AI response
+func (p *anthropicProvider) Infer(ctx context.Context, req InferenceRequest) (*anthropic.Message, error) {
+	anthropicTools := []anthropic.ToolUnionParam{}
+	for _, tool := range req.Tools {
+		anthropicTools = append(anthropicTools, anthropic.ToolUnionParam{OfTool: toolParam(tool)})
+	}
+
+	params := anthropic.MessageNewParams{
+		Model:     req.Model,
+		MaxTokens: req.MaxTokens,
+		Messages:  req.Conversation,
+		Tools:     anthropicTools,
+	}
+	if req.SystemPrompt != "" {
+		params.System = []anthropic.TextBlockParam{{Text: req.SystemPrompt}}
+	}
+	if req.ThinkingBudget > 0 {
+		// Thinking blocks, signatures included, stay in the conversation through message.ToParam,
+		// which the API requires for tool use to continue after thinking.
+		params.Thinking = anthropic.ThinkingConfigParamOfThinkingConfigEnabled(req.ThinkingBudget)
+	}
+
+	for attempt := 0; ; attempt++ {
+		message, started, err := p.streamMessage(ctx, params)
+		if err == nil {
+			return message, nil
+		}
+		// Once output has been printed a retry would repeat it, so only retry failed requests.
+		delay, retryable := retryDelay(err, attempt)
+		if started || !retryable || attempt >= p.maxRetries || ctx.Err() != nil {
+			return nil, err
+		}
+		fmt.Fprintf(p.notes, "API request failed, retrying in %s (attempt %d of %d): %s\n", delay.Round(time.Millisecond), attempt+1, p.maxRetries, err)
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// CountTokens asks the API how many input tokens the request would use
+func (p *anthropicProvider) CountTokens(ctx context.Context, req InferenceRequest) (int64, error) {
+	params := anthropic.MessageCountTokensParams{
+		Model:    req.Model,
+		Messages: req.Conversation,
+	}
+	if req.SystemPrompt != "" {
+		params.System.OfMessageCountTokenssSystemArray = []anthropic.TextBlockParam{{Text: req.SystemPrompt}}
+	}
+	for _, tool := range req.Tools {
+		params.Tools = append(params.Tools, anthropic.MessageCountTokensToolUnionParam{OfTool: toolParam(tool)})
+	}
+	count, err := p.client.Messages.CountTokens(ctx, params)
+	if err != nil {
+		return 0, err
+	}
+	return count.InputTokens, nil
+}
+
+// toolParam converts a tool definition to its API representation
+func toolParam(tool ToolDefinition) *anthropic.ToolParam {
+	return &anthropic.ToolParam{
+		Name:        tool.Name,
+		Description: anthropic.String(tool.Description),
+		InputSchema: tool.InputSchema,
+	}
+}
+
+// streamMessage streams a single AI response, printing text as it arrives, and reports whether any events were received
+func (p *anthropicProvider) streamMessage(ctx context.Context, params anthropic.MessageNewParams) (*anthropic.Message, bool, error) {
+	// Retries are handled by Infer so they can honor MaxRetries.
+	stream := p.client.Messages.NewStreaming(ctx, params, option.WithMaxRetries(0))
+	defer stream.Close()
+
+	message := anthropic.Message{}
+	started := false
+	for stream.Next() {
+		started = true
+		event := stream.Current()
+		// Tool calls without arguments stream an empty partial JSON delta,
+		// which would otherwise replace the "{}" input with invalid JSON.
+		if event.Type == "content_block_delta" && event.Delta.Type == "input_json_delta" && event.Delta.PartialJSON == "" {
+			continue
+		}
+		if err := message.Accumulate(event); err != nil {
+			return nil, started, err
+		}
+
+		switch event.Type {
+		case "content_block_start":
+			switch event.ContentBlock.Type {
+			case "text":
+				fmt.Fprint(p.out, colorize(colorYellow, "Claude")+": ")
+			case "thinking":
+				if p.showThinking {
+					fmt.Fprint(p.out, colorize(colorGray, "Thinking: "))
+				}
+			case "redacted_thinking":
+				if p.showThinking {
+					fmt.Fprintln(p.out, colorize(colorGray, "Thinking: [encrypted by the safety systems]"))
+				}
+			case "tool_use":
+				// Tool calls are printed when they run.
+			default:
+				// Say so rather than drop it silently. It is left out of the history, see messageParam.
+				fmt.Fprintln(p.out, colorize(colorGray, fmt.Sprintf("[The response contains a %s block that oeN can't display]", event.ContentBlock.Type)))
+			}
+		case "content_block_delta":
+			switch event.Delta.Type {
+			case "text_delta":
+				fmt.Fprint(p.out, event.Delta.Text)
+			case "thinking_delta":
+				if p.showThinking {
+					fmt.Fprint(p.out, colorize(colorGray, event.Delta.Thinking))
+				}
+			}
+		case "content_block_stop":
+			switch message.Content[len(message.Content)-1].Type {
+			case "text":
+				fmt.Fprintln(p.out)
+			case "thinking":
+				if p.showThinking {
+					fmt.Fprintln(p.out)
+				}
+			}
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, started, err
+	}
+	return &message, started, nil
+}
@@ -0,0 +1,35 @@
+package agent
+
+import "os"
+
+// ANSI color codes used in terminal output
+const (
+	colorGray   = "90"
+	colorRed    = "91"
+	colorGreen  = "92"
+	colorYellow = "93"
+	colorBlue   = "94"
+)
+
+// colorEnabled reports whether output should contain ANSI color escapes
+var colorEnabled = detectColor()
+
+// detectColor disables color for NO_COLOR, dumb terminals, and output that isn't a terminal
+func detectColor() bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorize wraps text in the given ANSI color when color output is enabled
+func colorize(code, text string) string {
+	if !colorEnabled {
+		return text
+	}
+	return "\u001b[" + code + "m" + text + "\u001b[0m"
+}
@@ -0,0 +1,130 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	anthropic "github.com/anthropics/anthropic-sdk-go"
+)
+
+// defaultCompactKeepTurns is the number of recent turns /compact keeps verbatim
+const defaultCompactKeepTurns = 2
+
+// runCommand handles a slash command typed by the user without sending it to the model.
+// It reports false if input is not a known command.
+func (a *Agent) runCommand(ctx context.Context, input string, conversation []anthropic.MessageParam) ([]anthropic.MessageParam, bool) {
+	fields := strings.Fields(input)
+	if len(fields) == 0 {
+		return conversation, false
+	}
+	switch fields[0] {
+	case "/help":
+		fmt.Fprintln(a.out, "Commands:")
+		fmt.Fprintln(a.out, "  /tools              list the tools the model can use")
+		fmt.Fprintln(a.out, "  /tokens             show how many tokens the conversation takes up")
+		fmt.Fprintln(a.out, "  /compact [turns]    summarize the conversation, keeping the last turns verbatim (default 2)")
+		fmt.Fprintln(a.out, "  /changed            list the files changed by tools in this session")
+		fmt.Fprintln(a.out, "  /undo               revert the most recent file change made by a tool")
+		fmt.Fprintln(a.out, "  /verbose            toggle the token usage and cost footer after each response")
+		fmt.Fprintln(a.out, "  /help               show this help")
+		return conversation, true
+	case "/tools":
+		for _, tool := range a.tools {
+			fmt.Fprintf(a.out, "%s: %s\n", colorize(colorGreen, tool.Name), firstLine(tool.Description))
+		}
+		return conversation, true
+	case "/tokens":
+		tokens, exact, err := a.CountTokens(ctx, conversation)
+		if err != nil {
+			fmt.Fprintln(a.out, colorize(colorRed, "Error")+": "+err.Error())
+			return conversation, true
+		}
+		approx := ""
+		if !exact {
+			approx = "~"
+		}
+		fmt.Fprintf(a.out, "Context: %s%d tokens of %d (%.0f%%) in %d messages\n", approx, tokens, a.config.ContextWindow, float64(tokens)*100/float64(a.config.ContextWindow), len(conversation))
+		return conversation, true
+	case "/changed":
+		changed := a.ChangedFiles()
+		if len(changed) == 0 {
+			fmt.Fprintln(a.out, "No files changed yet")
+			return conversation, true
+		}
+		for _, p := range changed {
+			fmt.Fprintln(a.out, p)
+		}
+		return conversation, true
+	case "/undo":
+		a.undo(ctx)
+		return conversation, true
+	case "/verbose":
+		a.config.Verbose = !a.config.Verbose
+		state := "off"
+		if a.config.Verbose {
+			state = "on"
+		}
+		fmt.Fprintf(a.out, "Usage footer is %s\n", state)
+		return conversation, true
+	case "/compact":
+		keep := defaultCompactKeepTurns
+		if len(fields) > 1 {
+			n, err := strconv.Atoi(fields[1])
+			if err != nil || n < 1 {
+				fmt.Fprintln(a.out, "Usage: /compact [turns to keep, at least 1]")
+				return conversation, true
+			}
+			keep = n
+		}
+		return a.compact(ctx, conversation, keep), true
+	default:
+		return conversation, false
+	}
+}
+
+// compact replaces all but the last keep turns with a model-written summary
+func (a *Agent) compact(ctx context.Context, conversation []anthropic.MessageParam, keep int) []anthropic.MessageParam {
+	starts := turnStarts(conversation)
+	if len(starts) <= keep {
+		fmt.Fprintf(a.out, "Nothing to compact, the conversation has %d turns\n", len(starts))
+		return conversation
+	}
+	cut := starts[len(starts)-keep]
+	compacted, err := a.summarize(ctx, conversation, cut)
+	if err != nil {
+		fmt.Fprintln(a.out, colorize(colorRed, "Error")+": "+err.Error())
+		return conversation
+	}
+	fmt.Fprintf(a.out, "Compacted %d messages into a summary, kept the last %d turns\n", cut, keep)
+	return compacted
+}
+
+// undo runs the undo tool on behalf of the user, who has already confirmed by typing the command
+func (a *Agent) undo(ctx context.Context) {
+	for _, tool := range a.tools {
+		if tool.Name != "undo" {
+			continue
+		}
+		result, err := tool.Function(ctx, json.RawMessage("{}"))
+		if err != nil {
+			fmt.Fprintln(a.out, colorize(colorRed, "Error")+": "+err.Error())
+			return
+		}
+		fmt.Fprintln(a.out, result)
+		return
+	}
+	fmt.Fprintln(a.out, "Undo is not available, the undo tool is not registered")
+}
+
+// firstLine returns the first non-empty line of s
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
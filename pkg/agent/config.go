@@ -0,0 +1,343 @@
+package agent
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	anthropic "github.com/anthropics/anthropic-sdk-go"
+)
+
+// DefaultModel is used when no model is configured
+const DefaultModel = anthropic.ModelClaude3_7SonnetLatest
+
+// DefaultMaxTokens is used when no max tokens value is configured
+const DefaultMaxTokens = 4096
+
+// DefaultMaxRetries is the number of times a failed API request is retried by default
+const DefaultMaxRetries = 3
+
+// DefaultToolTimeout is how long a tool may run before its result is given up on
+const DefaultToolTimeout = 30 * time.Second
+
+// DefaultMaxToolOutput is the largest tool result, in bytes, passed to the model by default. At roughly
+// four bytes per token it keeps a single result to about 25000 tokens.
+const DefaultMaxToolOutput = 100000
+
+// DefaultMaxTurns is the default number of model round-trips allowed per user message
+const DefaultMaxTurns = 50
+
+// MinThinkingBudget is the smallest extended thinking budget the API accepts, in tokens
+const MinThinkingBudget = 1024
+
+// DefaultContextWindow is the context window of the known Claude models, in tokens, and is assumed for other models unless configured
+const DefaultContextWindow = 200000
+
+// Strategies for keeping the conversation within the context window
+const (
+	// ContextDrop drops the oldest turns
+	ContextDrop = "drop"
+	// ContextSummarize replaces the oldest turns with a model-written summary
+	ContextSummarize = "summarize"
+	// ContextTiered summarizes early and in steps: the oldest third at half the context window,
+	// the oldest two thirds at 80%, and like ContextSummarize if that isn't enough
+	ContextTiered = "tiered"
+)
+
+// modelInfo describes a model accepted by the agent
+type modelInfo struct {
+	Name      anthropic.Model
+	MaxTokens int64
+	// InputPrice and OutputPrice are in USD per million tokens
+	InputPrice  float64
+	OutputPrice float64
+	// Thinking is whether the model supports extended thinking
+	Thinking bool
+}
+
+// knownModels lists the models accepted by the agent with their output token limits and pricing
+var knownModels = []modelInfo{
+	{anthropic.ModelClaude3_7SonnetLatest, 64000, 3, 15, true},
+	{anthropic.ModelClaude3_7Sonnet20250219, 64000, 3, 15, true},
+	{anthropic.ModelClaude3_5HaikuLatest, 8192, 0.8, 4, false},
+	{anthropic.ModelClaude3_5Haiku20241022, 8192, 0.8, 4, false},
+	{anthropic.ModelClaude3_5SonnetLatest, 8192, 3, 15, false},
+	{anthropic.ModelClaude3_5Sonnet20241022, 8192, 3, 15, false},
+	{anthropic.ModelClaude_3_5_Sonnet_20240620, 8192, 3, 15, false},
+	{anthropic.ModelClaude3OpusLatest, 4096, 15, 75, false},
+	{anthropic.ModelClaude_3_Opus_20240229, 4096, 15, 75, false},
+	{anthropic.ModelClaude_3_Haiku_20240307, 4096, 0.25, 1.25, false},
+}
+
+// lookupModel returns the info for a known model
+func lookupModel(name anthropic.Model) (modelInfo, bool) {
+	for _, m := range knownModels {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return modelInfo{}, false
+}
+
+// Config holds tunable settings for the agent
+type Config struct {
+	// Provider selects the model backend, either ProviderAnthropic (the default) or ProviderOpenAI
+	Provider string
+	// OpenAIBaseURL and OpenAIAPIKey configure the OpenAI-compatible backend
+	OpenAIBaseURL string
+	OpenAIAPIKey  string
+	Model         anthropic.Model
+	MaxTokens     int64
+	SystemPrompt  string
+	// ThinkingBudget enables extended thinking with up to this many tokens per response when positive.
+	// The thinking counts towards MaxTokens, which must be larger.
+	ThinkingBudget int64
+	// ShowThinking prints the model's thinking, dimmed, instead of hiding it
+	ShowThinking bool
+	// MaxRetries is how often rate-limited or overloaded API requests are retried
+	MaxRetries int
+	// ContextWindow is the model's context size in tokens, used to decide when to shorten the conversation
+	ContextWindow int64
+	// ContextStrategy is ContextDrop (the default), ContextSummarize, or ContextTiered
+	ContextStrategy string
+	// ToolTimeout limits how long a single tool call may take
+	ToolTimeout time.Duration
+	// MaxToolOutput is the largest tool result in bytes; longer results are truncated before the model sees them
+	MaxToolOutput int
+	// RedactSecrets masks API keys, tokens, and other likely secrets in tool output before the model sees it
+	// or it is logged. RedactPatterns are regular expressions matched in addition to DefaultRedactPatterns.
+	RedactSecrets  bool
+	RedactPatterns []string
+	// MaxTurns limits model round-trips per user message so a tool loop can't run forever
+	MaxTurns int
+	// InitialContext, if set, is sent along with the first user message, e.g. files the user attached
+	InitialContext string
+	// Prompt, if set, is sent as the only user message and Run returns once the model stops using tools
+	Prompt string
+	// ToolLogger, if set, receives a structured record of every tool call
+	ToolLogger *slog.Logger
+	// Verbose prints token usage after every response
+	Verbose bool
+	// Quiet leaves out the tool calls, except for failed ones, status notes, and the changed files,
+	// so that little more than the model's answers is printed
+	Quiet bool
+	// SessionFile, if set, is loaded on start and rewritten after every turn
+	SessionFile string
+	// PromptPrefix is shown before the interactive prompt, e.g. the name of the directory the tools work in
+	PromptPrefix string
+	// AutoApprove skips the confirmation prompt for tools that require one
+	AutoApprove bool
+	// Policy overrides whether tools ask for confirmation, keyed by tool name or category such as CategoryEdit.
+	// A tool's name takes precedence over its category.
+	Policy map[string]Policy
+	// Output receives all user-facing output and defaults to os.Stdout
+	Output io.Writer
+	// Watcher, if set, is asked for files changed outside the agent before every user message
+	Watcher FileWatcher
+	// Transaction, if set, makes the file changes of each model response all or nothing: once a tool in
+	// CategoryEdit or CategoryDelete fails, the response's remaining such calls are skipped and the changes rolled back
+	Transaction Transaction
+	// Metrics, if set, is told the duration and result of every tool call that runs
+	Metrics Metrics
+}
+
+// FileWatcher reports files that changed since it was last asked
+type FileWatcher interface {
+	Changes() ([]string, error)
+}
+
+// Metrics records tool calls, e.g. to find slow tools. RecordToolCall may be called concurrently.
+type Metrics interface {
+	RecordToolCall(name string, dur time.Duration, err error)
+}
+
+// Transaction records the files tools change so that the changes can be reverted together
+type Transaction interface {
+	Begin()
+	Commit()
+	// Rollback restores the files changed since Begin and returns their paths
+	Rollback() ([]string, error)
+}
+
+// ConfigFromEnv builds a Config from OEN_* environment variables
+func ConfigFromEnv() (Config, error) {
+	config := Config{
+		Provider:        os.Getenv("OEN_PROVIDER"),
+		OpenAIBaseURL:   os.Getenv("OEN_OPENAI_BASE_URL"),
+		OpenAIAPIKey:    os.Getenv("OPENAI_API_KEY"),
+		Model:           os.Getenv("OEN_MODEL"),
+		SystemPrompt:    os.Getenv("OEN_SYSTEM_PROMPT"),
+		ContextStrategy: os.Getenv("OEN_CONTEXT_STRATEGY"),
+		MaxRetries:      DefaultMaxRetries,
+	}
+	if v := os.Getenv("OEN_MAX_TOKENS"); v != "" {
+		maxTokens, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || maxTokens <= 0 {
+			return Config{}, fmt.Errorf("invalid OEN_MAX_TOKENS %q: must be a positive integer", v)
+		}
+		config.MaxTokens = maxTokens
+	}
+	if v := os.Getenv("OEN_THINKING_BUDGET"); v != "" {
+		budget, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || budget < 0 {
+			return Config{}, fmt.Errorf("invalid OEN_THINKING_BUDGET %q: must be a non-negative integer", v)
+		}
+		config.ThinkingBudget = budget
+	}
+	if v := os.Getenv("OEN_CONTEXT_WINDOW"); v != "" {
+		contextWindow, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || contextWindow <= 0 {
+			return Config{}, fmt.Errorf("invalid OEN_CONTEXT_WINDOW %q: must be a positive integer", v)
+		}
+		config.ContextWindow = contextWindow
+	}
+	if v := os.Getenv("OEN_TOOL_TIMEOUT"); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil || timeout <= 0 {
+			return Config{}, fmt.Errorf("invalid OEN_TOOL_TIMEOUT %q: must be a positive duration such as 30s", v)
+		}
+		config.ToolTimeout = timeout
+	}
+	if v := os.Getenv("OEN_MAX_TOOL_OUTPUT"); v != "" {
+		maxToolOutput, err := strconv.Atoi(v)
+		if err != nil || maxToolOutput <= 0 {
+			return Config{}, fmt.Errorf("invalid OEN_MAX_TOOL_OUTPUT %q: must be a positive integer", v)
+		}
+		config.MaxToolOutput = maxToolOutput
+	}
+	if v := os.Getenv("OEN_REDACT_SECRETS"); v != "" {
+		redact, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid OEN_REDACT_SECRETS %q: must be true or false", v)
+		}
+		config.RedactSecrets = redact
+	}
+	if v := os.Getenv("OEN_LOG_FILE"); v != "" {
+		logger, err := newToolLogger(v)
+		if err != nil {
+			return Config{}, err
+		}
+		config.ToolLogger = logger
+	}
+	if v := os.Getenv("OEN_MAX_RETRIES"); v != "" {
+		maxRetries, err := strconv.Atoi(v)
+		if err != nil || maxRetries < 0 {
+			return Config{}, fmt.Errorf("invalid OEN_MAX_RETRIES %q: must be a non-negative integer", v)
+		}
+		config.MaxRetries = maxRetries
+	}
+	return config, nil
+}
+
+// output returns the configured output writer or os.Stdout
+func (c Config) output() io.Writer {
+	if c.Output == nil {
+		return os.Stdout
+	}
+	return c.Output
+}
+
+// notes returns the writer for status notes, such as retries, which Quiet discards
+func (c Config) notes() io.Writer {
+	if c.Quiet {
+		return io.Discard
+	}
+	return c.output()
+}
+
+// validate fills in defaults and checks the config for invalid values
+func (c *Config) validate() error {
+	c.Output = c.output()
+	if c.MaxTurns <= 0 {
+		c.MaxTurns = DefaultMaxTurns
+	}
+	if c.ToolTimeout <= 0 {
+		c.ToolTimeout = DefaultToolTimeout
+	}
+	if c.MaxToolOutput <= 0 {
+		c.MaxToolOutput = DefaultMaxToolOutput
+	}
+	if c.ContextWindow <= 0 {
+		c.ContextWindow = DefaultContextWindow
+	}
+	if err := validatePolicy(c.Policy); err != nil {
+		return err
+	}
+	switch c.ContextStrategy {
+	case "":
+		c.ContextStrategy = ContextDrop
+	case ContextDrop, ContextSummarize, ContextTiered:
+	default:
+		return fmt.Errorf("unknown context strategy %q; valid options: %s, %s, %s", c.ContextStrategy, ContextDrop, ContextSummarize, ContextTiered)
+	}
+	// Other backends serve arbitrary models, so only Anthropic models are checked against knownModels.
+	if c.Provider != "" && c.Provider != ProviderAnthropic {
+		if c.ThinkingBudget > 0 {
+			return fmt.Errorf("extended thinking is only supported by the %s provider", ProviderAnthropic)
+		}
+		if c.Model == "" {
+			return fmt.Errorf("a model must be set when using the %s provider", c.Provider)
+		}
+		if c.MaxTokens <= 0 {
+			c.MaxTokens = DefaultMaxTokens
+		}
+		return nil
+	}
+
+	if c.Model == "" {
+		c.Model = DefaultModel
+	}
+	model, ok := lookupModel(c.Model)
+	if !ok {
+		names := make([]string, len(knownModels))
+		for i, m := range knownModels {
+			names[i] = m.Name
+		}
+		return fmt.Errorf("unknown model %q; valid options: %s", c.Model, strings.Join(names, ", "))
+	}
+
+	if c.MaxTokens <= 0 {
+		// Thinking shares the output budget, so leave the usual room for the answer on top of it.
+		c.MaxTokens = min(DefaultMaxTokens+c.ThinkingBudget, model.MaxTokens)
+	}
+	if c.MaxTokens > model.MaxTokens {
+		fmt.Fprintf(c.Output, "Warning: max tokens %d exceeds the %s limit of %d, using %d\n", c.MaxTokens, model.Name, model.MaxTokens, model.MaxTokens)
+		c.MaxTokens = model.MaxTokens
+	}
+	if c.ThinkingBudget > 0 {
+		if !model.Thinking {
+			return fmt.Errorf("model %s does not support extended thinking", model.Name)
+		}
+		if c.ThinkingBudget < MinThinkingBudget {
+			return fmt.Errorf("thinking budget %d is below the minimum of %d tokens", c.ThinkingBudget, MinThinkingBudget)
+		}
+		if c.ThinkingBudget >= c.MaxTokens {
+			return fmt.Errorf("thinking budget %d must be less than max tokens %d", c.ThinkingBudget, c.MaxTokens)
+		}
+	}
+	return nil
+}
+
+// newToolLogger returns a logger that appends JSON lines to the file at path
+func newToolLogger(path string) (*slog.Logger, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	handler := slog.NewJSONHandler(f, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			switch attr.Key {
+			case slog.TimeKey:
+				attr.Key = "timestamp"
+			case slog.LevelKey:
+				return slog.Attr{}
+			}
+			return attr
+		},
+	})
+	return slog.New(handler), nil
+}
@@ -0,0 +1,199 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	anthropic "github.com/anthropics/anthropic-sdk-go"
+)
+
+// charsPerToken is the rough ratio used to estimate token counts from serialized size
+const charsPerToken = 4
+
+// The conversation is shortened once it fills contextHighWater of the available
+// context, down to contextLowWater so that it doesn't need shortening again right away.
+const (
+	contextHighWater = 0.9
+	contextLowWater  = 0.6
+)
+
+// compactionTier summarizes the oldest fraction of the conversation, measured in tokens,
+// once it fills threshold of the available context
+type compactionTier struct {
+	threshold float64
+	fraction  float64
+}
+
+// compactionTiers are the steps of ContextTiered, from the lowest threshold to the highest
+var compactionTiers = []compactionTier{
+	{threshold: 0.5, fraction: 1.0 / 3},
+	{threshold: 0.8, fraction: 2.0 / 3},
+}
+
+// summaryPrompt asks the model to summarize the conversation before it is dropped
+const summaryPrompt = "Summarize our conversation so far for your own future reference. Include the user's goals, decisions made, files read or changed, and any open tasks. Reply with the summary only and do not call any tools."
+
+// estimateTokens roughly estimates the number of tokens v takes up in a request
+func estimateTokens(v any) int64 {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return int64(len(data) / charsPerToken)
+}
+
+// EstimateTextTokens roughly estimates the number of tokens text takes up, the same way the
+// conversation is measured when the provider can't count tokens
+func EstimateTextTokens(text string) int64 {
+	return int64(len(text) / charsPerToken)
+}
+
+// estimateOverhead roughly estimates the tokens the system prompt and tool definitions add to every request
+func (a *Agent) estimateOverhead() int64 {
+	tokens := EstimateTextTokens(a.config.SystemPrompt)
+	for _, tool := range a.tools {
+		tokens += estimateTokens(tool.InputSchema) + EstimateTextTokens(tool.Description)
+	}
+	return tokens
+}
+
+// CountTokens returns the number of input tokens the conversation would use in the next request.
+// Providers that can't count tokens get a rough estimate instead, which is reported by exact being false.
+func (a *Agent) CountTokens(ctx context.Context, conversation []anthropic.MessageParam) (tokens int64, exact bool, err error) {
+	if counter, ok := a.provider.(TokenCounter); ok && len(conversation) > 0 {
+		tokens, err := counter.CountTokens(ctx, a.inferenceRequest(conversation))
+		return tokens, true, err
+	}
+	return estimateTokens(conversation) + a.estimateOverhead(), false, nil
+}
+
+// turnStarts returns the indexes of user messages that begin a turn, i.e. that carry no tool results.
+// Cutting the conversation only at these indexes never separates a tool_use from its tool_result.
+func turnStarts(conversation []anthropic.MessageParam) []int {
+	var starts []int
+	for i, msg := range conversation {
+		if msg.Role != anthropic.MessageParamRoleUser {
+			continue
+		}
+		isToolResult := false
+		for _, block := range msg.Content {
+			if block.OfRequestToolResultBlock != nil {
+				isToolResult = true
+				break
+			}
+		}
+		if !isToolResult {
+			starts = append(starts, i)
+		}
+	}
+	return starts
+}
+
+// fitContext shortens the conversation with the configured strategy when it nears the context window
+func (a *Agent) fitContext(ctx context.Context, conversation []anthropic.MessageParam) ([]anthropic.MessageParam, error) {
+	budget := a.config.ContextWindow - a.config.MaxTokens - a.estimateOverhead()
+
+	// suffix[i] estimates the tokens of conversation[i:].
+	suffix := make([]int64, len(conversation)+1)
+	for i := len(conversation) - 1; i >= 0; i-- {
+		suffix[i] = suffix[i+1] + estimateTokens(conversation[i])
+	}
+	if a.config.ContextStrategy == ContextTiered {
+		if cut := a.tieredCut(conversation, suffix, budget); cut > 0 {
+			return a.summarize(ctx, conversation, cut)
+		}
+	}
+	if float64(suffix[0]) <= float64(budget)*contextHighWater {
+		return conversation, nil
+	}
+
+	// Cut at the earliest turn that brings the rest under the low-water mark,
+	// but always keep the latest turn even if it is too large on its own.
+	cut := 0
+	for _, start := range turnStarts(conversation) {
+		if start == 0 {
+			continue
+		}
+		cut = start
+		if float64(suffix[start]) <= float64(budget)*contextLowWater {
+			break
+		}
+	}
+	if cut == 0 {
+		return conversation, nil
+	}
+
+	if a.config.ContextStrategy != ContextDrop {
+		a.notef("Conversation is nearing the context window, summarizing %d earlier messages", cut)
+		return a.summarize(ctx, conversation, cut)
+	}
+	a.notef("Conversation is nearing the context window, dropped %d earlier messages", cut)
+	return conversation[cut:], nil
+}
+
+// tieredCut returns where to summarize the conversation when it has crossed a compaction tier that hasn't
+// fired yet, or 0. suffix[i] estimates the tokens of conversation[i:].
+func (a *Agent) tieredCut(conversation []anthropic.MessageParam, suffix []int64, budget int64) int {
+	total := suffix[0]
+	level := 0
+	for level < len(compactionTiers) && float64(total) >= float64(budget)*compactionTiers[level].threshold {
+		level++
+	}
+	if level <= a.compactLevel {
+		// Below every tier, or compacting at this tier didn't get the conversation below it, so
+		// wait for the next tier rather than summarizing again every turn.
+		a.compactLevel = level
+		return 0
+	}
+
+	// Cut at the first turn start past the oldest fraction, but always keep the latest turn.
+	tier := compactionTiers[level-1]
+	cut := 0
+	for _, start := range turnStarts(conversation) {
+		if start == 0 {
+			continue
+		}
+		cut = start
+		if float64(total-suffix[start]) >= float64(total)*tier.fraction {
+			break
+		}
+	}
+	if cut == 0 {
+		return 0
+	}
+	a.compactLevel = level
+	a.notef("Conversation uses %d%% of the context window, summarizing %d earlier messages", int(float64(total)/float64(budget)*100), cut)
+	return cut
+}
+
+// summarize asks the model to summarize conversation[:cut] and returns the rest of the
+// conversation with the summary prepended to its first message. cut must be a turn start.
+func (a *Agent) summarize(ctx context.Context, conversation []anthropic.MessageParam, cut int) ([]anthropic.MessageParam, error) {
+	request := append(conversation[:cut:cut], anthropic.NewUserMessage(anthropic.NewTextBlock(summaryPrompt)))
+	// Tools stay defined because the API rejects tool_use blocks in history otherwise.
+	message, err := a.provider.Infer(ctx, a.inferenceRequest(request))
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize conversation: %w", err)
+	}
+	a.recordUsage(message.Usage)
+
+	var summary []string
+	for _, content := range message.Content {
+		if content.Type == "text" {
+			summary = append(summary, content.Text)
+		}
+	}
+	if len(summary) == 0 {
+		return nil, fmt.Errorf("failed to summarize conversation: the model returned no text")
+	}
+
+	kept := append([]anthropic.MessageParam{}, conversation[cut:]...)
+	first := kept[0]
+	first.Content = append([]anthropic.ContentBlockParamUnion{
+		anthropic.NewTextBlock("Summary of the earlier conversation:\n" + strings.Join(summary, "\n")),
+	}, first.Content...)
+	kept[0] = first
+	return kept, nil
+}
@@ -0,0 +1,107 @@
+package agent
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// toolStatsBuckets are the upper bounds of the duration buckets ToolStats counts calls in; the last bucket is open
+var toolStatsBuckets = []time.Duration{10 * time.Millisecond, 100 * time.Millisecond, time.Second, 10 * time.Second}
+
+// ToolStats is a Metrics that keeps call counts and durations per tool in memory
+type ToolStats struct {
+	mu    sync.Mutex
+	tools map[string]*toolStat
+}
+
+// toolStat holds the calls of one tool
+type toolStat struct {
+	calls   int
+	errors  int
+	total   time.Duration
+	max     time.Duration
+	buckets []int
+}
+
+// NewToolStats returns an empty ToolStats
+func NewToolStats() *ToolStats {
+	return &ToolStats{tools: map[string]*toolStat{}}
+}
+
+// RecordToolCall counts a call of the tool name that took dur
+func (s *ToolStats) RecordToolCall(name string, dur time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stat, ok := s.tools[name]
+	if !ok {
+		stat = &toolStat{buckets: make([]int, len(toolStatsBuckets)+1)}
+		s.tools[name] = stat
+	}
+	stat.calls++
+	if err != nil {
+		stat.errors++
+	}
+	stat.total += dur
+	stat.max = max(stat.max, dur)
+	bucket := sort.Search(len(toolStatsBuckets), func(i int) bool { return dur < toolStatsBuckets[i] })
+	stat.buckets[bucket]++
+}
+
+// WriteReport writes a table of the recorded calls to w, the most called tool first, with the number
+// of calls that took up to each bucket's bound. Nothing is written if no tool was called.
+func (s *ToolStats) WriteReport(w io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.tools) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(s.tools))
+	for name := range s.tools {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := s.tools[names[i]], s.tools[names[j]]
+		if a.calls != b.calls {
+			return a.calls > b.calls
+		}
+		return names[i] < names[j]
+	})
+
+	// Numbers are right-aligned; padding the names to the same width keeps them left-aligned.
+	width := len("tool")
+	for _, name := range names {
+		width = max(width, len(name))
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "%-*s\tcalls\terrors\tavg\tmax\t", width, "tool")
+	for _, bound := range toolStatsBuckets {
+		fmt.Fprintf(tw, "<%s\t", bound)
+	}
+	fmt.Fprintf(tw, ">=%s\t\n", toolStatsBuckets[len(toolStatsBuckets)-1])
+	for _, name := range names {
+		stat := s.tools[name]
+		avg := stat.total / time.Duration(stat.calls)
+		fmt.Fprintf(tw, "%-*s\t%d\t%d\t%s\t%s\t", width, name, stat.calls, stat.errors, roundDuration(avg), roundDuration(stat.max))
+		for _, count := range stat.buckets {
+			fmt.Fprintf(tw, "%d\t", count)
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+// roundDuration rounds d to a precision that suits its size, e.g. 1.2ms or 3.4s
+func roundDuration(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(100 * time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(100 * time.Microsecond)
+	default:
+		return d.Round(time.Microsecond)
+	}
+}
@@ -0,0 +1,257 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	anthropic "github.com/anthropics/anthropic-sdk-go"
+)
+
+// DefaultOpenAIBaseURL is used when no OpenAI-compatible endpoint is configured
+const DefaultOpenAIBaseURL = "https://api.openai.com/v1"
+
+// openaiProvider talks to an OpenAI-compatible chat completions API such as OpenAI, Ollama, or llama.cpp
+type openaiProvider struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+	out     io.Writer
+}
+
+// newOpenAIProvider returns a provider for the chat completions API at baseURL that prints replies to out
+func newOpenAIProvider(baseURL, apiKey string, out io.Writer) *openaiProvider {
+	if baseURL == "" {
+		baseURL = DefaultOpenAIBaseURL
+	}
+	return &openaiProvider{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		client:  http.DefaultClient,
+		out:     out,
+	}
+}
+
+// openaiMessage is a chat message in the OpenAI wire format
+type openaiMessage struct {
+	Role       string           `json:"role"`
+	Content    *string          `json:"content"`
+	ToolCalls  []openaiToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+// openaiToolCall is a function call requested by the model
+type openaiToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// openaiTool describes a function the model may call
+type openaiTool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string         `json:"name"`
+		Description string         `json:"description"`
+		Parameters  map[string]any `json:"parameters"`
+	} `json:"function"`
+}
+
+// openaiRequest is the body of a chat completions request
+type openaiRequest struct {
+	Model     string          `json:"model"`
+	MaxTokens int64           `json:"max_tokens"`
+	Messages  []openaiMessage `json:"messages"`
+	Tools     []openaiTool    `json:"tools,omitempty"`
+}
+
+// openaiResponse is the subset of a chat completions response the agent uses
+type openaiResponse struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Message      openaiMessage `json:"message"`
+		FinishReason string        `json:"finish_reason"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int64 `json:"prompt_tokens"`
+		CompletionTokens int64 `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+// Infer sends the conversation to the chat completions endpoint and converts the reply to an Anthropic message
+func (p *openaiProvider) Infer(ctx context.Context, req InferenceRequest) (*anthropic.Message, error) {
+	messages, err := toOpenAIMessages(req.SystemPrompt, req.Conversation)
+	if err != nil {
+		return nil, err
+	}
+	body := openaiRequest{
+		Model:     req.Model,
+		MaxTokens: req.MaxTokens,
+		Messages:  messages,
+	}
+	for _, tool := range req.Tools {
+		parameters, err := tool.InputSchemaMap()
+		if err != nil {
+			return nil, fmt.Errorf("invalid input schema for tool %s: %w", tool.Name, err)
+		}
+		t := openaiTool{Type: "function"}
+		t.Function.Name = tool.Name
+		t.Function.Description = tool.Description
+		t.Function.Parameters = parameters
+		body.Tools = append(body.Tools, t)
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/chat/completions", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("chat completions request failed: %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	var completion openaiResponse
+	if err := json.Unmarshal(respBody, &completion); err != nil {
+		return nil, fmt.Errorf("invalid chat completions response: %w", err)
+	}
+	if len(completion.Choices) == 0 {
+		return nil, fmt.Errorf("chat completions response contains no choices")
+	}
+	message, err := fromOpenAIResponse(completion)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, content := range message.Content {
+		if content.Type == "text" {
+			fmt.Fprintf(p.out, "%s: %s\n", colorize(colorYellow, "Claude"), content.Text)
+		}
+	}
+	return message, nil
+}
+
+// toOpenAIMessages converts an Anthropic conversation to OpenAI chat messages.
+// Tool results become separate "tool" messages, as the OpenAI API expects.
+func toOpenAIMessages(systemPrompt string, conversation []anthropic.MessageParam) ([]openaiMessage, error) {
+	var messages []openaiMessage
+	if systemPrompt != "" {
+		messages = append(messages, openaiMessage{Role: "system", Content: &systemPrompt})
+	}
+	for _, msg := range conversation {
+		out := openaiMessage{Role: string(msg.Role)}
+		var text []string
+		var toolResults []openaiMessage
+		for _, block := range msg.Content {
+			switch {
+			case block.OfRequestTextBlock != nil:
+				text = append(text, block.OfRequestTextBlock.Text)
+			case block.OfRequestToolUseBlock != nil:
+				args, err := json.Marshal(block.OfRequestToolUseBlock.Input)
+				if err != nil {
+					return nil, err
+				}
+				call := openaiToolCall{ID: block.OfRequestToolUseBlock.ID, Type: "function"}
+				call.Function.Name = block.OfRequestToolUseBlock.Name
+				call.Function.Arguments = string(args)
+				out.ToolCalls = append(out.ToolCalls, call)
+			case block.OfRequestToolResultBlock != nil:
+				var parts []string
+				for _, c := range block.OfRequestToolResultBlock.Content {
+					if c.OfRequestTextBlock != nil {
+						parts = append(parts, c.OfRequestTextBlock.Text)
+					}
+				}
+				content := strings.Join(parts, "\n")
+				toolResults = append(toolResults, openaiMessage{
+					Role:       "tool",
+					Content:    &content,
+					ToolCallID: block.OfRequestToolResultBlock.ToolUseID,
+				})
+			}
+			// Thinking and other Anthropic-only blocks have no OpenAI equivalent and are dropped.
+		}
+		messages = append(messages, toolResults...)
+		if len(text) > 0 {
+			content := strings.Join(text, "\n")
+			out.Content = &content
+		}
+		if out.Content != nil || len(out.ToolCalls) > 0 {
+			messages = append(messages, out)
+		}
+	}
+	return messages, nil
+}
+
+// fromOpenAIResponse converts the first choice of a chat completion to an Anthropic message
+func fromOpenAIResponse(completion openaiResponse) (*anthropic.Message, error) {
+	choice := completion.Choices[0]
+	content := []map[string]any{}
+	if choice.Message.Content != nil && *choice.Message.Content != "" {
+		content = append(content, map[string]any{"type": "text", "text": *choice.Message.Content})
+	}
+	for _, call := range choice.Message.ToolCalls {
+		input := json.RawMessage(call.Function.Arguments)
+		if strings.TrimSpace(call.Function.Arguments) == "" {
+			input = json.RawMessage("{}")
+		}
+		if !json.Valid(input) {
+			return nil, fmt.Errorf("model returned invalid arguments for tool %s: %s", call.Function.Name, call.Function.Arguments)
+		}
+		content = append(content, map[string]any{"type": "tool_use", "id": call.ID, "name": call.Function.Name, "input": input})
+	}
+
+	stopReason := "end_turn"
+	switch choice.FinishReason {
+	case "tool_calls":
+		stopReason = "tool_use"
+	case "length":
+		stopReason = "max_tokens"
+	}
+
+	// Going through JSON keeps the SDK's raw fields populated, which ToParam relies on.
+	data, err := json.Marshal(map[string]any{
+		"id":          completion.ID,
+		"type":        "message",
+		"role":        "assistant",
+		"model":       completion.Model,
+		"content":     content,
+		"stop_reason": stopReason,
+		"usage": map[string]int64{
+			"input_tokens":  completion.Usage.PromptTokens,
+			"output_tokens": completion.Usage.CompletionTokens,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var message anthropic.Message
+	if err := json.Unmarshal(data, &message); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
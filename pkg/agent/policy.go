@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Policy decides whether a tool may run without asking the user
+type Policy string
+
+// Policies that can be set per tool or per category
+const (
+	// PolicyAuto runs the tool without asking, even if it normally requires confirmation
+	PolicyAuto Policy = "auto"
+	// PolicyPrompt asks the user before every call unless auto-approve is set
+	PolicyPrompt Policy = "prompt"
+	// PolicyDeny refuses every call
+	PolicyDeny Policy = "deny"
+)
+
+// Tool categories that policies can refer to instead of individual tools
+const (
+	CategoryRead    = "read"
+	CategoryEdit    = "edit"
+	CategoryDelete  = "delete"
+	CategoryCommand = "command"
+	CategoryNetwork = "network"
+)
+
+// category returns the tool's category, defaulting to CategoryRead for read-only tools and CategoryEdit otherwise
+func (t ToolDefinition) category() string {
+	switch {
+	case t.Category != "":
+		return t.Category
+	case t.ReadOnly:
+		return CategoryRead
+	default:
+		return CategoryEdit
+	}
+}
+
+// policyFor returns the policy for a tool: the one set for its name, else the one set for its category.
+// Without either, the tool asks for confirmation if it requires it.
+func (c Config) policyFor(tool ToolDefinition) Policy {
+	if p, ok := c.Policy[tool.Name]; ok {
+		return p
+	}
+	if p, ok := c.Policy[tool.category()]; ok {
+		return p
+	}
+	if tool.RequiresConfirmation {
+		return PolicyPrompt
+	}
+	return PolicyAuto
+}
+
+// needsConfirmation reports whether the user has to approve a call to the tool
+func (c Config) needsConfirmation(tool ToolDefinition) bool {
+	return c.policyFor(tool) == PolicyPrompt && !c.AutoApprove
+}
+
+// validatePolicy checks that every policy is one of the known values
+func validatePolicy(policy map[string]Policy) error {
+	keys := make([]string, 0, len(policy))
+	for key := range policy {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		switch policy[key] {
+		case PolicyAuto, PolicyPrompt, PolicyDeny:
+		default:
+			return fmt.Errorf("invalid policy %q for %s; valid options: %s, %s, %s", policy[key], key, PolicyAuto, PolicyPrompt, PolicyDeny)
+		}
+	}
+	return nil
+}
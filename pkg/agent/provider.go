@@ -0,0 +1,48 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	anthropic "github.com/anthropics/anthropic-sdk-go"
+)
+
+// Supported values for Config.Provider
+const (
+	ProviderAnthropic = "anthropic"
+	ProviderOpenAI    = "openai"
+)
+
+// InferenceRequest holds everything a Provider needs to produce the next assistant message
+type InferenceRequest struct {
+	Model        string
+	MaxTokens    int64
+	SystemPrompt string
+	// ThinkingBudget enables extended thinking when positive
+	ThinkingBudget int64
+	Conversation   []anthropic.MessageParam
+	Tools          []ToolDefinition
+}
+
+// Provider sends a conversation to a model backend and returns the assistant's reply.
+// Conversations are kept in the Anthropic message format regardless of the backend.
+type Provider interface {
+	Infer(ctx context.Context, req InferenceRequest) (*anthropic.Message, error)
+}
+
+// TokenCounter is implemented by providers that can count the input tokens of a request exactly
+type TokenCounter interface {
+	CountTokens(ctx context.Context, req InferenceRequest) (int64, error)
+}
+
+// NewProvider returns the Provider selected by config.Provider
+func NewProvider(config Config) (Provider, error) {
+	switch config.Provider {
+	case "", ProviderAnthropic:
+		return newAnthropicProvider(config.MaxRetries, config.ShowThinking, config.output(), config.notes()), nil
+	case ProviderOpenAI:
+		return newOpenAIProvider(config.OpenAIBaseURL, config.OpenAIAPIKey, config.output()), nil
+	default:
+		return nil, fmt.Errorf("unknown provider %q; valid options: %s, %s", config.Provider, ProviderAnthropic, ProviderOpenAI)
+	}
+}
@@ -0,0 +1,119 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// redactedText replaces secrets in tool output
+const redactedText = "[REDACTED]"
+
+// DefaultRedactPatterns match common secrets. When a pattern has a capture group, only the
+// first group is masked, so that e.g. the name of an assignment stays readable.
+var DefaultRedactPatterns = []string{
+	// AWS access key IDs and secret access keys
+	`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`,
+	`(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})`,
+	// Authorization headers
+	`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]+=*)`,
+	// API keys with well-known prefixes: OpenAI and Anthropic, GitHub, Slack
+	`\bsk-[A-Za-z0-9_-]{20,}`,
+	`\bgh[pousr]_[A-Za-z0-9]{36,}\b`,
+	`\bxox[abpr]-[A-Za-z0-9-]{10,}`,
+	// PEM private keys
+	`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`,
+	// Values assigned to names that suggest a secret, as in .env files and configs
+	`(?i)(?:api_?key|secret|token|passw(?:or)?d)["']?\s*[:=]\s*["']?([^\s"',;]{8,})`,
+}
+
+// highEntropyCandidate matches long tokens that may be random keys
+var highEntropyCandidate = regexp.MustCompile(`[A-Za-z0-9+/=_-]{32,}`)
+
+// minSecretEntropy is the Shannon entropy in bits per character above which a candidate that also
+// contains a digit is treated as a key. Hex strings such as commit hashes stay below it.
+const minSecretEntropy = 4.3
+
+// redactor masks secrets in text
+type redactor struct {
+	patterns []*regexp.Regexp
+}
+
+// newRedactor compiles DefaultRedactPatterns and the given extra patterns
+func newRedactor(extra []string) (*redactor, error) {
+	r := &redactor{}
+	for _, pattern := range append(append([]string(nil), DefaultRedactPatterns...), extra...) {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %w", pattern, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// redact returns s with every match of the patterns and every high-entropy token replaced by redactedText
+func (r *redactor) redact(s string) string {
+	for _, re := range r.patterns {
+		s = maskMatches(re, s)
+	}
+	return highEntropyCandidate.ReplaceAllStringFunc(s, func(token string) string {
+		// Long identifiers can be as varied as keys, but rarely contain digits.
+		if !strings.ContainsAny(token, "0123456789") || entropy(token) < minSecretEntropy {
+			return token
+		}
+		return redactedText
+	})
+}
+
+// maskMatches replaces the matches of re in s, or only their first capture group if re has one
+func maskMatches(re *regexp.Regexp, s string) string {
+	matches := re.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+	var result []byte
+	last := 0
+	for _, match := range matches {
+		start, end := match[0], match[1]
+		if len(match) > 2 && match[2] >= 0 {
+			start, end = match[2], match[3]
+		}
+		result = append(result, s[last:start]...)
+		result = append(result, redactedText...)
+		last = end
+	}
+	return string(append(result, s[last:]...))
+}
+
+// entropy returns the Shannon entropy of s in bits per character
+func entropy(s string) float64 {
+	counts := map[rune]int{}
+	n := 0
+	for _, c := range s {
+		counts[c]++
+		n++
+	}
+	var bits float64
+	for _, count := range counts {
+		p := float64(count) / float64(n)
+		bits -= p * math.Log2(p)
+	}
+	return bits
+}
+
+// redact masks secrets in a tool's output and error if redaction is enabled, keeping the error's code
+func (a *Agent) redact(output string, err error) (string, error) {
+	if a.redactor == nil {
+		return output, err
+	}
+	output = a.redactor.redact(output)
+	if err != nil {
+		if msg := a.redactor.redact(err.Error()); msg != err.Error() {
+			err = &ToolError{Code: ErrorCode(err), Err: errors.New(msg)}
+		}
+	}
+	return output, err
+}
@@ -0,0 +1,63 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Registry collects the tools available to an agent
+type Registry struct {
+	tools []ToolDefinition
+}
+
+// DefaultRegistry is the registry that tool packages register into from init
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds a tool to the registry
+func (r *Registry) Register(tool ToolDefinition) {
+	r.tools = append(r.tools, tool)
+}
+
+// All returns the registered tools in registration order
+func (r *Registry) All() []ToolDefinition {
+	return append([]ToolDefinition(nil), r.tools...)
+}
+
+// Select returns the registered tools, restricted to the enable list if it is non-empty and without
+// the tools in the disable list. Names that don't match a registered tool are an error.
+func (r *Registry) Select(enable, disable []string) ([]ToolDefinition, error) {
+	known := map[string]bool{}
+	for _, tool := range r.tools {
+		known[tool.Name] = true
+	}
+	var unknown []string
+	for _, name := range append(append([]string(nil), enable...), disable...) {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown tools: %s", strings.Join(unknown, ", "))
+	}
+
+	enabled := map[string]bool{}
+	for _, name := range enable {
+		enabled[name] = true
+	}
+	disabled := map[string]bool{}
+	for _, name := range disable {
+		disabled[name] = true
+	}
+	var selected []ToolDefinition
+	for _, tool := range r.tools {
+		if (len(enable) == 0 || enabled[tool.Name]) && !disabled[tool.Name] {
+			selected = append(selected, tool)
+		}
+	}
+	return selected, nil
+}
@@ -0,0 +1,56 @@
+package agent
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+
+	anthropic "github.com/anthropics/anthropic-sdk-go"
+)
+
+// ToolCall records a single tool call made while answering a user message
+type ToolCall struct {
+	Name    string          `json:"name"`
+	Input   json.RawMessage `json:"input"`
+	Output  string          `json:"output"`
+	IsError bool            `json:"is_error"`
+	// ErrorCode classifies the failure of a call that returned an error
+	ErrorCode ToolErrorCode `json:"error_code,omitempty"`
+}
+
+// TurnResult describes how the agent answered the most recent user message
+type TurnResult struct {
+	// Text is the text of the model's last response
+	Text      string     `json:"text"`
+	ToolCalls []ToolCall `json:"tool_calls"`
+}
+
+// LastTurn returns the final text and tool calls of the most recent user message, e.g. the prompt of a non-interactive run
+func (a *Agent) LastTurn() TurnResult {
+	return a.lastTurn
+}
+
+// messageText joins the text blocks of a model response
+func messageText(message *anthropic.Message) string {
+	var texts []string
+	for _, content := range message.Content {
+		if content.Type == "text" {
+			texts = append(texts, content.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// messageParam converts a model response for the conversation history. Blocks of types the SDK
+// doesn't know convert to empty params, which the API would reject, so they are left out.
+func messageParam(message *anthropic.Message) anthropic.MessageParam {
+	param := message.ToParam()
+	param.Content = slices.DeleteFunc(param.Content, func(block anthropic.ContentBlockParamUnion) bool {
+		return block.GetType() == nil
+	})
+	if len(param.Content) == 0 {
+		// An assistant message must not be empty.
+		param.Content = append(param.Content, anthropic.NewTextBlock("[This response only contained content that could not be kept.]"))
+	}
+	return param
+}
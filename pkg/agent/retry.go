@@ -0,0 +1,73 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	anthropic "github.com/anthropics/anthropic-sdk-go"
+)
+
+// Backoff bounds for retried API requests
+const (
+	retryBaseDelay = time.Second
+	retryMaxDelay  = 30 * time.Second
+)
+
+// retryDelay reports whether err is worth retrying and how long to wait before attempt+1
+func retryDelay(err error, attempt int) (time.Duration, bool) {
+	var apiErr *anthropic.Error
+	if !errors.As(err, &apiErr) {
+		return 0, false
+	}
+	switch apiErr.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, 529:
+	default:
+		return 0, false
+	}
+
+	if apiErr.Response != nil {
+		if delay, ok := parseRetryAfter(apiErr.Response.Header); ok {
+			return delay, true
+		}
+	}
+
+	// Exponential backoff with jitter in [delay/2, delay). Doubling stops at the cap so that no
+	// number of attempts can overflow the delay.
+	delay := retryBaseDelay
+	for i := 0; i < attempt && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, retryMaxDelay)
+	return delay/2 + rand.N(delay/2), true
+}
+
+// parseRetryAfter reads the delay requested by the server, if any
+func parseRetryAfter(header http.Header) (time.Duration, bool) {
+	if ms, err := strconv.ParseFloat(header.Get("Retry-After-Ms"), 64); err == nil && ms >= 0 {
+		return time.Duration(ms * float64(time.Millisecond)), true
+	}
+	value := header.Get("Retry-After")
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,69 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	anthropic "github.com/anthropics/anthropic-sdk-go"
+)
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		attempt   int
+		wantRetry bool
+		min, max  time.Duration
+	}{
+		{name: "first attempt", err: &anthropic.Error{StatusCode: http.StatusTooManyRequests}, attempt: 0, wantRetry: true, min: retryBaseDelay / 2, max: retryBaseDelay},
+		{name: "doubles", err: &anthropic.Error{StatusCode: 529}, attempt: 2, wantRetry: true, min: 2 * retryBaseDelay, max: 4 * retryBaseDelay},
+		{name: "capped", err: &anthropic.Error{StatusCode: http.StatusInternalServerError}, attempt: 10, wantRetry: true, min: retryMaxDelay / 2, max: retryMaxDelay},
+		{name: "shift would overflow", err: &anthropic.Error{StatusCode: http.StatusTooManyRequests}, attempt: 63, wantRetry: true, min: retryMaxDelay / 2, max: retryMaxDelay},
+		{name: "shift past width", err: &anthropic.Error{StatusCode: http.StatusTooManyRequests}, attempt: 1000, wantRetry: true, min: retryMaxDelay / 2, max: retryMaxDelay},
+		{name: "negative attempt", err: &anthropic.Error{StatusCode: http.StatusTooManyRequests}, attempt: -1, wantRetry: true, min: retryBaseDelay / 2, max: retryBaseDelay},
+		{name: "client error", err: &anthropic.Error{StatusCode: http.StatusBadRequest}},
+		{name: "not an API error", err: errors.New("connection reset")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 20 {
+				delay, retry := retryDelay(tt.err, tt.attempt)
+				if retry != tt.wantRetry {
+					t.Fatalf("retryDelay() retry = %v, want %v", retry, tt.wantRetry)
+				}
+				if retry && (delay < tt.min || delay >= tt.max) {
+					t.Fatalf("retryDelay() = %s, want in [%s, %s)", delay, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryNoticesRespectQuiet(t *testing.T) {
+	tests := []struct {
+		name  string
+		quiet bool
+	}{
+		{name: "normal"},
+		{name: "quiet", quiet: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			provider, err := NewProvider(Config{Quiet: tt.quiet, Output: &out})
+			if err != nil {
+				t.Fatal(err)
+			}
+			fmt.Fprint(provider.(*anthropicProvider).notes, "retrying")
+			if got := out.String() != ""; got == tt.quiet {
+				t.Errorf("retry notice printed = %v with Quiet %v", got, tt.quiet)
+			}
+		})
+	}
+}
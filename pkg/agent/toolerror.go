@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+// ToolErrorCode classifies why a tool call failed
+type ToolErrorCode string
+
+// Codes reported for failed tool calls
+const (
+	// ToolErrorFailed is used for errors that fit no other code
+	ToolErrorFailed           ToolErrorCode = "failed"
+	ToolErrorUnknownTool      ToolErrorCode = "unknown_tool"
+	ToolErrorInvalidInput     ToolErrorCode = "invalid_input"
+	ToolErrorDeclined         ToolErrorCode = "declined"
+	ToolErrorPolicyDenied     ToolErrorCode = "denied_by_policy"
+	ToolErrorNotFound         ToolErrorCode = "not_found"
+	ToolErrorAlreadyExists    ToolErrorCode = "already_exists"
+	ToolErrorPermissionDenied ToolErrorCode = "permission_denied"
+	ToolErrorPathEscape       ToolErrorCode = "path_escape"
+	ToolErrorIgnored          ToolErrorCode = "ignored"
+	ToolErrorTimeout          ToolErrorCode = "timeout"
+	ToolErrorRolledBack       ToolErrorCode = "rolled_back"
+)
+
+// ToolError is a tool failure with a code that callers can act on.
+// Tools return it for failures that the standard error values don't already describe.
+type ToolError struct {
+	Code ToolErrorCode
+	Err  error
+}
+
+// NewToolError returns a ToolError with a message formatted like fmt.Errorf, so %w can wrap a cause
+func NewToolError(code ToolErrorCode, format string, args ...any) *ToolError {
+	return &ToolError{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+func (e *ToolError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ToolError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorCode returns the code of the first ToolError in err's chain, or derives one from
+// well-known errors such as fs.ErrNotExist
+func ErrorCode(err error) ToolErrorCode {
+	var toolErr *ToolError
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &toolErr):
+		return toolErr.Code
+	case errors.Is(err, fs.ErrNotExist):
+		return ToolErrorNotFound
+	case errors.Is(err, fs.ErrExist):
+		return ToolErrorAlreadyExists
+	case errors.Is(err, fs.ErrPermission):
+		return ToolErrorPermissionDenied
+	case errors.Is(err, context.DeadlineExceeded):
+		return ToolErrorTimeout
+	default:
+		return ToolErrorFailed
+	}
+}
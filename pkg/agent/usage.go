@@ -0,0 +1,45 @@
+package agent
+
+import (
+	"fmt"
+
+	anthropic "github.com/anthropics/anthropic-sdk-go"
+)
+
+// Usage holds token counts accumulated over a session
+type Usage struct {
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+	// Cost is the estimated cost in USD
+	Cost float64 `json:"cost"`
+}
+
+// String formats the usage for display
+func (u Usage) String() string {
+	return fmt.Sprintf("%d input tokens, %d output tokens, ~$%.4f", u.InputTokens, u.OutputTokens, u.Cost)
+}
+
+// Usage returns the token usage accumulated so far in the session
+func (a *Agent) Usage() Usage {
+	return a.usage
+}
+
+// recordUsage adds the usage of a single response, priced by the model's entry in knownModels,
+// to the session totals and returns the response's share
+func (a *Agent) recordUsage(usage anthropic.Usage) Usage {
+	model, _ := lookupModel(a.config.Model)
+	turn := Usage{
+		InputTokens:  usage.InputTokens,
+		OutputTokens: usage.OutputTokens,
+		Cost:         (float64(usage.InputTokens)*model.InputPrice + float64(usage.OutputTokens)*model.OutputPrice) / 1e6,
+	}
+	a.usage.add(turn)
+	return turn
+}
+
+// add adds other to u
+func (u *Usage) add(other Usage) {
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.Cost += other.Cost
+}
@@ -0,0 +1,144 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	anthropic "github.com/anthropics/anthropic-sdk-go"
+)
+
+// jsonSchema is the subset of JSON schema emitted for tool inputs that the agent validates
+type jsonSchema struct {
+	Type       string                 `json:"type"`
+	Properties map[string]*jsonSchema `json:"properties"`
+	Required   []string               `json:"required"`
+	Items      *jsonSchema            `json:"items"`
+	Enum       []any                  `json:"enum"`
+	Minimum    *float64               `json:"minimum"`
+	Maximum    *float64               `json:"maximum"`
+	Pattern    string                 `json:"pattern"`
+}
+
+// parseSchema converts a tool input schema into a jsonSchema
+func parseSchema(schema anthropic.ToolInputSchemaParam) (*jsonSchema, error) {
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	var s jsonSchema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// validateInput checks a tool input against its schema and describes every problem found
+func validateInput(schema *jsonSchema, input json.RawMessage) error {
+	decoder := json.NewDecoder(bytes.NewReader(input))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("input is not valid JSON: %w", err)
+	}
+
+	var problems []string
+	schema.validate("input", value, &problems)
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid input:\n- %s", strings.Join(problems, "\n- "))
+	}
+	return nil
+}
+
+// validate appends a problem for every way value violates the schema
+func (s *jsonSchema) validate(path string, value any, problems *[]string) {
+	if s.Type != "" && !matchesType(s.Type, value) {
+		*problems = append(*problems, fmt.Sprintf("%s: expected %s, got %s", path, s.Type, typeName(value)))
+		return
+	}
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(e any) bool { return fmt.Sprint(e) == fmt.Sprint(value) }) {
+		*problems = append(*problems, fmt.Sprintf("%s: must be one of %v", path, s.Enum))
+	}
+
+	switch v := value.(type) {
+	case json.Number:
+		n, _ := v.Float64()
+		if s.Minimum != nil && n < *s.Minimum {
+			*problems = append(*problems, fmt.Sprintf("%s: must be at least %v", path, *s.Minimum))
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			*problems = append(*problems, fmt.Sprintf("%s: must be at most %v", path, *s.Maximum))
+		}
+	case string:
+		if s.Pattern != "" {
+			// Schemas are generated from trusted struct tags, so an invalid pattern is simply not enforced.
+			if re, err := regexp.Compile(s.Pattern); err == nil && !re.MatchString(v) {
+				*problems = append(*problems, fmt.Sprintf("%s: must match pattern %s", path, s.Pattern))
+			}
+		}
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				*problems = append(*problems, fmt.Sprintf("%s.%s: required field is missing", path, name))
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if prop, ok := s.Properties[name]; ok {
+				prop.validate(path+"."+name, v[name], problems)
+			}
+		}
+	case []any:
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, problems)
+			}
+		}
+	}
+}
+
+// matchesType reports whether value is of the given JSON schema type
+func matchesType(schemaType string, value any) bool {
+	switch schemaType {
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := n.Int64()
+		return err == nil
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	default:
+		return typeName(value) == schemaType
+	}
+}
+
+// typeName returns the JSON schema type name of a decoded JSON value
+func typeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
@@ -0,0 +1,187 @@
+// Package config loads optional oen.yaml config files.
+//
+// Settings are applied in order of precedence: command-line flags override the
+// config file, which overrides OEN_* environment variables.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/MarkusZoppelt/oen/pkg/agent"
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the config file looked up by Find
+const FileName = "oen.yaml"
+
+// File holds the settings of a config file. Fields that are left out keep their value from the environment.
+type File struct {
+	Model     string `yaml:"model"`
+	MaxTokens int64  `yaml:"max_tokens"`
+	// ThinkingBudget enables extended thinking, like OEN_THINKING_BUDGET
+	ThinkingBudget int64  `yaml:"thinking_budget"`
+	SystemPrompt   string `yaml:"system_prompt"`
+	// MaxToolOutput is the largest tool result in bytes, like OEN_MAX_TOOL_OUTPUT
+	MaxToolOutput int `yaml:"max_tool_output"`
+	// EnabledTools and DisabledTools select tools like --enable-tool and --disable-tool
+	EnabledTools  []string `yaml:"enabled_tools"`
+	DisabledTools []string `yaml:"disabled_tools"`
+	// Policy maps tool names or categories to auto, prompt, or deny
+	Policy map[string]agent.Policy `yaml:"policy"`
+	// RedactSecrets masks likely secrets in tool output, like OEN_REDACT_SECRETS. RedactPatterns are
+	// regular expressions of further secrets to mask.
+	RedactSecrets  bool     `yaml:"redact_secrets"`
+	RedactPatterns []string `yaml:"redact_patterns"`
+	// Transactional rolls back a response's file edits if one of its tool calls fails, like --transactional
+	Transactional bool `yaml:"transactional"`
+	// VetCommand replaces go vet for the go_vet tool, e.g. "golangci-lint run", like OEN_VET_COMMAND
+	VetCommand string `yaml:"vet_command"`
+	// MaxRemoveEntries is how many entries remove_directory deletes recursively without force; negative turns the limit off
+	MaxRemoveEntries int `yaml:"max_remove_entries"`
+	// TemplatesDir is the directory render_template loads templates from, like OEN_TEMPLATES_DIR.
+	// Relative paths are relative to the config file.
+	TemplatesDir string `yaml:"templates_dir"`
+	// Workspace lists further directories tool paths may lead into, like those in .oen-workspace.
+	// Relative paths are relative to the config file.
+	Workspace []string `yaml:"workspace"`
+	// Root is the directory tool paths are confined to. Relative paths are relative to the config file.
+	Root string `yaml:"root"`
+}
+
+// SearchPaths returns the locations Find checks, in order: the working directory, then ~/.config/oen
+func SearchPaths() []string {
+	paths := []string{FileName}
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, ".config", "oen", FileName))
+	}
+	return paths
+}
+
+// Find returns the first config file that exists in SearchPaths, or "" if there is none
+func Find() (string, error) {
+	for _, p := range SearchPaths() {
+		_, err := os.Stat(p)
+		if err == nil {
+			return p, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// Load reads and parses the config file at path. Unknown keys are an error so that typos don't go unnoticed.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file File
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	// An empty file decodes to io.EOF and simply sets nothing.
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if file.MaxTokens < 0 {
+		return nil, fmt.Errorf("invalid max_tokens in %s: must not be negative", path)
+	}
+	if file.ThinkingBudget < 0 {
+		return nil, fmt.Errorf("invalid thinking_budget in %s: must not be negative", path)
+	}
+	if file.MaxToolOutput < 0 {
+		return nil, fmt.Errorf("invalid max_tool_output in %s: must not be negative", path)
+	}
+	if file.Root != "" && !filepath.IsAbs(file.Root) {
+		file.Root = filepath.Join(filepath.Dir(path), file.Root)
+	}
+	for i, dir := range file.Workspace {
+		if !filepath.IsAbs(dir) {
+			file.Workspace[i] = filepath.Join(filepath.Dir(path), dir)
+		}
+		if info, err := os.Stat(file.Workspace[i]); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("invalid workspace root %s in %s: not a directory", dir, path)
+		}
+	}
+	if file.TemplatesDir != "" && !filepath.IsAbs(file.TemplatesDir) {
+		file.TemplatesDir = filepath.Join(filepath.Dir(path), file.TemplatesDir)
+	}
+	return &file, nil
+}
+
+// Apply overrides the settings of c that are set in the file
+func (f *File) Apply(c *agent.Config) {
+	if f.Model != "" {
+		c.Model = f.Model
+	}
+	if f.MaxTokens > 0 {
+		c.MaxTokens = f.MaxTokens
+	}
+	if f.SystemPrompt != "" {
+		c.SystemPrompt = f.SystemPrompt
+	}
+	if f.ThinkingBudget > 0 {
+		c.ThinkingBudget = f.ThinkingBudget
+	}
+	if f.MaxToolOutput > 0 {
+		c.MaxToolOutput = f.MaxToolOutput
+	}
+	if len(f.Policy) > 0 {
+		c.Policy = f.Policy
+	}
+	if f.RedactSecrets {
+		c.RedactSecrets = true
+	}
+	if len(f.RedactPatterns) > 0 {
+		c.RedactPatterns = f.RedactPatterns
+	}
+}
+
+// Flags holds the settings given on the command line, which take precedence over the config file.
+// Fields that are left empty are not set by a flag.
+type Flags struct {
+	SystemPrompt   string
+	ThinkingBudget int64
+	RedactSecrets  bool
+	EnabledTools   []string
+	DisabledTools  []string
+	// ReadOnly selects the read-only tools, so the enabled tools of the file don't apply
+	ReadOnly bool
+}
+
+// AgentConfig returns the agent settings, taking each from the flags, then the file, then the environment
+func (f *File) AgentConfig(flags Flags) (agent.Config, error) {
+	c, err := agent.ConfigFromEnv()
+	if err != nil {
+		return agent.Config{}, err
+	}
+	f.Apply(&c)
+	if flags.SystemPrompt != "" {
+		c.SystemPrompt = flags.SystemPrompt
+	}
+	if flags.ThinkingBudget > 0 {
+		c.ThinkingBudget = flags.ThinkingBudget
+	}
+	if flags.RedactSecrets {
+		c.RedactSecrets = true
+	}
+	return c, nil
+}
+
+// Tools returns the tools to enable and disable, taken from the flags or else from the file
+func (f *File) Tools(flags Flags) (enabled, disabled []string) {
+	enabled, disabled = flags.EnabledTools, flags.DisabledTools
+	if len(enabled) == 0 && !flags.ReadOnly {
+		enabled = f.EnabledTools
+	}
+	if len(disabled) == 0 {
+		disabled = f.DisabledTools
+	}
+	return enabled, disabled
+}
@@ -0,0 +1,228 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/MarkusZoppelt/oen/pkg/tools"
+)
+
+func TestPrecedence(t *testing.T) {
+	tests := []struct {
+		name          string
+		env           map[string]string
+		file          string
+		flags         Flags
+		wantModel     string
+		wantMaxTokens int64
+		wantPrompt    string
+		wantThinking  int64
+		wantRedact    bool
+	}{
+		{
+			name:          "environment only",
+			env:           map[string]string{"OEN_MODEL": "claude-3-5-haiku-latest", "OEN_MAX_TOKENS": "100", "OEN_SYSTEM_PROMPT": "from env"},
+			wantModel:     "claude-3-5-haiku-latest",
+			wantMaxTokens: 100,
+			wantPrompt:    "from env",
+		},
+		{
+			name:          "file overrides environment",
+			env:           map[string]string{"OEN_MODEL": "claude-3-5-haiku-latest", "OEN_MAX_TOKENS": "100", "OEN_SYSTEM_PROMPT": "from env"},
+			file:          "model: claude-3-opus-latest\nmax_tokens: 200\nsystem_prompt: from file\n",
+			wantModel:     "claude-3-opus-latest",
+			wantMaxTokens: 200,
+			wantPrompt:    "from file",
+		},
+		{
+			name:          "environment kept where the file is silent",
+			env:           map[string]string{"OEN_MODEL": "claude-3-5-haiku-latest", "OEN_MAX_TOKENS": "100", "OEN_SYSTEM_PROMPT": "from env"},
+			file:          "model: claude-3-opus-latest\n",
+			wantModel:     "claude-3-opus-latest",
+			wantMaxTokens: 100,
+			wantPrompt:    "from env",
+		},
+		{
+			name:          "flags override file and environment",
+			env:           map[string]string{"OEN_MODEL": "claude-3-5-haiku-latest", "OEN_MAX_TOKENS": "100", "OEN_SYSTEM_PROMPT": "from env"},
+			file:          "system_prompt: from file\n",
+			flags:         Flags{SystemPrompt: "from flags"},
+			wantModel:     "claude-3-5-haiku-latest",
+			wantMaxTokens: 100,
+			wantPrompt:    "from flags",
+		},
+		{
+			name:          "flags override environment",
+			env:           map[string]string{"OEN_MODEL": "claude-3-5-haiku-latest", "OEN_MAX_TOKENS": "100", "OEN_SYSTEM_PROMPT": "from env"},
+			flags:         Flags{SystemPrompt: "from flags"},
+			wantModel:     "claude-3-5-haiku-latest",
+			wantMaxTokens: 100,
+			wantPrompt:    "from flags",
+		},
+		{
+			name:          "thinking budget",
+			env:           map[string]string{"OEN_MAX_TOKENS": "8000", "OEN_THINKING_BUDGET": "1024"},
+			file:          "thinking_budget: 2048\n",
+			flags:         Flags{ThinkingBudget: 4096},
+			wantMaxTokens: 8000,
+			wantThinking:  4096,
+		},
+		{
+			name:       "redaction turned on by a flag",
+			flags:      Flags{RedactSecrets: true},
+			wantRedact: true,
+		},
+		{
+			name:       "redaction turned on by the file",
+			file:       "redact_secrets: true\n",
+			wantRedact: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"OEN_MODEL", "OEN_MAX_TOKENS", "OEN_SYSTEM_PROMPT", "OEN_THINKING_BUDGET", "OEN_REDACT_SECRETS"} {
+				t.Setenv(key, tt.env[key])
+			}
+			path := filepath.Join(t.TempDir(), FileName)
+			if err := os.WriteFile(path, []byte(tt.file), 0644); err != nil {
+				t.Fatal(err)
+			}
+			file, err := Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			config, err := file.AgentConfig(tt.flags)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Model != tt.wantModel {
+				t.Errorf("Model = %q, want %q", config.Model, tt.wantModel)
+			}
+			if config.MaxTokens != tt.wantMaxTokens {
+				t.Errorf("MaxTokens = %d, want %d", config.MaxTokens, tt.wantMaxTokens)
+			}
+			if config.SystemPrompt != tt.wantPrompt {
+				t.Errorf("SystemPrompt = %q, want %q", config.SystemPrompt, tt.wantPrompt)
+			}
+			if config.ThinkingBudget != tt.wantThinking {
+				t.Errorf("ThinkingBudget = %d, want %d", config.ThinkingBudget, tt.wantThinking)
+			}
+			if config.RedactSecrets != tt.wantRedact {
+				t.Errorf("RedactSecrets = %v, want %v", config.RedactSecrets, tt.wantRedact)
+			}
+		})
+	}
+}
+
+func TestTools(t *testing.T) {
+	file := &File{EnabledTools: []string{"read_file"}, DisabledTools: []string{"edit_file"}}
+	tests := []struct {
+		name         string
+		flags        Flags
+		wantEnabled  []string
+		wantDisabled []string
+	}{
+		{name: "file", wantEnabled: []string{"read_file"}, wantDisabled: []string{"edit_file"}},
+		{
+			name:         "flags override file",
+			flags:        Flags{EnabledTools: []string{"list_files"}, DisabledTools: []string{"write_file"}},
+			wantEnabled:  []string{"list_files"},
+			wantDisabled: []string{"write_file"},
+		},
+		{name: "read-only ignores enabled tools of the file", flags: Flags{ReadOnly: true}, wantDisabled: []string{"edit_file"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enabled, disabled := file.Tools(tt.flags)
+			if !slices.Equal(enabled, tt.wantEnabled) {
+				t.Errorf("enabled = %v, want %v", enabled, tt.wantEnabled)
+			}
+			if !slices.Equal(disabled, tt.wantDisabled) {
+				t.Errorf("disabled = %v, want %v", disabled, tt.wantDisabled)
+			}
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		name       string
+		workingDir bool
+		home       bool
+		want       string
+	}{
+		{name: "none"},
+		{name: "home", home: true, want: "HOME"},
+		{name: "working directory", workingDir: true, want: FileName},
+		{name: "working directory before home", workingDir: true, home: true, want: FileName},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			t.Chdir(t.TempDir())
+			homeFile := filepath.Join(home, ".config", "oen", FileName)
+			if tt.home {
+				if err := os.MkdirAll(filepath.Dir(homeFile), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(homeFile, nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.workingDir {
+				if err := os.WriteFile(FileName, nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := Find()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.want
+			if want == "HOME" {
+				want = homeFile
+			}
+			if got != want {
+				t.Errorf("Find() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadResolvesPaths(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "api"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, FileName)
+	if err := os.WriteFile(path, []byte("root: src\nworkspace: [api]\ntemplates_dir: tmpl\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Root != filepath.Join(dir, "src") {
+		t.Errorf("Root = %s, want it relative to the config file", file.Root)
+	}
+	if len(file.Workspace) != 1 || file.Workspace[0] != filepath.Join(dir, "api") {
+		t.Errorf("Workspace = %v, want it relative to the config file", file.Workspace)
+	}
+	if file.TemplatesDir != filepath.Join(dir, "tmpl") {
+		t.Errorf("TemplatesDir = %s, want it relative to the config file", file.TemplatesDir)
+	}
+}
+
+func TestFileNameMatchesTools(t *testing.T) {
+	if FileName != tools.ConfigFileName {
+		t.Errorf("FileName = %q, but the tools protect %q", FileName, tools.ConfigFileName)
+	}
+}
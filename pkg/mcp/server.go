@@ -0,0 +1,170 @@
+// Package mcp exposes agent tools as a Model Context Protocol server over stdio.
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/MarkusZoppelt/oen/pkg/agent"
+)
+
+// ProtocolVersion is the MCP revision implemented by the server
+const ProtocolVersion = "2024-11-05"
+
+// JSON-RPC error codes used by the server
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+)
+
+// request is an incoming JSON-RPC message; requests without an ID are notifications
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// response is an outgoing JSON-RPC reply
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError is the error member of a JSON-RPC reply
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// tool is a tool entry in a tools/list result
+type tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+}
+
+// content is a content item in a tools/call result
+type content struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// callResult is the result of a tools/call request
+type callResult struct {
+	Content []content `json:"content"`
+	IsError bool      `json:"isError"`
+}
+
+// Server answers MCP requests by running the given tools
+type Server struct {
+	name    string
+	version string
+	tools   []agent.ToolDefinition
+	// runner runs the calls like calls from the model, so that its policy, timeouts, and limits apply
+	runner *agent.Agent
+}
+
+// NewServer creates a Server that advertises itself under name and version and runs the tools
+// through runner, which must have been created with the same tools
+func NewServer(name, version string, tools []agent.ToolDefinition, runner *agent.Agent) *Server {
+	return &Server{name: name, version: version, tools: tools, runner: runner}
+}
+
+// Serve reads newline-delimited JSON-RPC messages from r and writes replies to w until r is exhausted or ctx is done
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	encoder := json.NewEncoder(w)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var req request
+		if err := json.Unmarshal(line, &req); err != nil {
+			if err := encoder.Encode(response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{codeParseError, err.Error()}}); err != nil {
+				return err
+			}
+			continue
+		}
+		result, rpcErr := s.handle(ctx, req)
+		if req.ID == nil {
+			// Notifications never get a reply.
+			continue
+		}
+		if err := encoder.Encode(response{JSONRPC: "2.0", ID: req.ID, Result: result, Error: rpcErr}); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// handle dispatches a single request to its method
+func (s *Server) handle(ctx context.Context, req request) (any, *rpcError) {
+	if req.JSONRPC != "2.0" {
+		return nil, &rpcError{codeInvalidRequest, `jsonrpc must be "2.0"`}
+	}
+	switch req.Method {
+	case "initialize":
+		return map[string]any{
+			"protocolVersion": ProtocolVersion,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]any{"name": s.name, "version": s.version},
+		}, nil
+	case "ping":
+		return map[string]any{}, nil
+	case "tools/list":
+		tools := make([]tool, 0, len(s.tools))
+		for _, t := range s.tools {
+			schema, err := t.InputSchemaMap()
+			if err != nil {
+				return nil, &rpcError{codeInternalError, fmt.Sprintf("invalid input schema for tool %s: %s", t.Name, err)}
+			}
+			tools = append(tools, tool{Name: t.Name, Description: t.Description, InputSchema: schema})
+		}
+		return map[string]any{"tools": tools}, nil
+	case "tools/call":
+		return s.callTool(ctx, req.Params)
+	default:
+		if req.ID == nil {
+			// Unknown notifications such as notifications/initialized need no handling.
+			return nil, nil
+		}
+		return nil, &rpcError{codeMethodNotFound, fmt.Sprintf("method not found: %s", req.Method)}
+	}
+}
+
+// callTool runs the requested tool, reporting tool failures as an error result rather than a protocol error
+func (s *Server) callTool(ctx context.Context, raw json.RawMessage) (any, *rpcError) {
+	var params struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, &rpcError{codeInvalidParams, err.Error()}
+	}
+	if len(params.Arguments) == 0 || string(params.Arguments) == "null" {
+		params.Arguments = json.RawMessage("{}")
+	}
+	for _, t := range s.tools {
+		if t.Name != params.Name {
+			continue
+		}
+		call := s.runner.CallTool(ctx, params.Name, params.Arguments)
+		return callResult{Content: []content{{Type: "text", Text: call.Output}}, IsError: call.IsError}, nil
+	}
+	return nil, &rpcError{codeInvalidParams, fmt.Sprintf("unknown tool: %s", params.Name)}
+}
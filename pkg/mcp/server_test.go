@@ -0,0 +1,97 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/MarkusZoppelt/oen/pkg/agent"
+	anthropic "github.com/anthropics/anthropic-sdk-go"
+)
+
+func TestCallTool(t *testing.T) {
+	ran := map[string]bool{}
+	tool := func(name, output string, confirm bool) agent.ToolDefinition {
+		schema := anthropic.ToolInputSchemaParam{Properties: map[string]any{"path": map[string]any{"type": "string"}}}
+		schema.WithExtraFields(map[string]any{"required": []string{"path"}})
+		return agent.ToolDefinition{
+			Name:        name,
+			InputSchema: schema,
+			Function: func(ctx context.Context, input json.RawMessage) (string, error) {
+				ran[name] = true
+				return output, nil
+			},
+			RequiresConfirmation: confirm,
+		}
+	}
+	tools := []agent.ToolDefinition{
+		tool("read", "content", false),
+		tool("remove", "removed", false),
+		tool("confirm", "confirmed", true),
+		tool("large", strings.Repeat("x", 100), false),
+	}
+
+	tests := []struct {
+		name        string
+		tool        string
+		arguments   string
+		autoApprove bool
+		wantText    string
+		wantError   bool
+		wantRun     bool
+	}{
+		{name: "allowed", tool: "read", arguments: `{"path":"a"}`, wantText: "content", wantRun: true},
+		{name: "denied by policy", tool: "remove", arguments: `{"path":"a"}`, wantText: "denied by policy", wantError: true},
+		{name: "invalid input", tool: "read", arguments: `{}`, wantText: "path", wantError: true},
+		{name: "needs confirmation", tool: "confirm", arguments: `{"path":"a"}`, wantText: "declined", wantError: true},
+		{name: "confirmation auto-approved", tool: "confirm", arguments: `{"path":"a"}`, autoApprove: true, wantText: "confirmed", wantRun: true},
+		{name: "output limited", tool: "large", arguments: `{"path":"a"}`, wantText: "truncated", wantRun: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clear(ran)
+			runner, err := agent.NewAgent(nil, func() (string, bool) { return "", false }, tools, agent.Config{
+				Policy:        map[string]agent.Policy{"remove": agent.PolicyDeny},
+				AutoApprove:   tt.autoApprove,
+				MaxToolOutput: 50,
+				Quiet:         true,
+				Output:        io.Discard,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			server := NewServer("oen", "test", tools, runner)
+			in := fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":%q,"arguments":%s}}`+"\n", tt.tool, tt.arguments)
+			var out bytes.Buffer
+			if err := server.Serve(context.Background(), strings.NewReader(in), &out); err != nil {
+				t.Fatal(err)
+			}
+
+			var resp struct {
+				Result callResult `json:"result"`
+				Error  *rpcError  `json:"error"`
+			}
+			if err := json.Unmarshal(out.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid response %q: %v", out.String(), err)
+			}
+			if resp.Error != nil {
+				t.Fatalf("error response: %s", resp.Error.Message)
+			}
+			text := ""
+			for _, c := range resp.Result.Content {
+				text += c.Text
+			}
+			if resp.Result.IsError != tt.wantError || !strings.Contains(text, tt.wantText) {
+				t.Errorf("result = %q (error %v), want one containing %q (error %v)", text, resp.Result.IsError, tt.wantText, tt.wantError)
+			}
+			if ran[tt.tool] != tt.wantRun {
+				t.Errorf("tool ran = %v, want %v", ran[tt.tool], tt.wantRun)
+			}
+		})
+	}
+}
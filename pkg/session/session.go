@@ -0,0 +1,121 @@
+// Package session persists agent conversations so they can be resumed later.
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	anthropic "github.com/anthropics/anthropic-sdk-go"
+)
+
+// message mirrors the JSON shape of an anthropic.MessageParam
+type message struct {
+	Role    anthropic.MessageParamRole `json:"role"`
+	Content []block                    `json:"content"`
+}
+
+// block mirrors the JSON shape of the content blocks the agent produces
+type block struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
+	Content   []block         `json:"content,omitempty"`
+	Thinking  string          `json:"thinking,omitempty"`
+	Signature string          `json:"signature,omitempty"`
+	Data      string          `json:"data,omitempty"`
+}
+
+// Save writes the conversation to path, replacing any previous contents
+func Save(path string, conversation []anthropic.MessageParam) error {
+	data, err := json.MarshalIndent(conversation, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+
+	// Write to a temp file first so an interrupted save never leaves a truncated session.
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+// Load reads a conversation from path, returning nil if the file does not exist
+func Load(path string) ([]anthropic.MessageParam, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+
+	var messages []message
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return nil, fmt.Errorf("failed to decode session %s: %w", path, err)
+	}
+
+	conversation := make([]anthropic.MessageParam, 0, len(messages))
+	for _, m := range messages {
+		blocks := make([]anthropic.ContentBlockParamUnion, 0, len(m.Content))
+		for _, b := range m.Content {
+			param, err := b.toParam()
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode session %s: %w", path, err)
+			}
+			blocks = append(blocks, param)
+		}
+		conversation = append(conversation, anthropic.MessageParam{Role: m.Role, Content: blocks})
+	}
+	return conversation, nil
+}
+
+// toParam converts a decoded block back into its SDK param type
+func (b block) toParam() (anthropic.ContentBlockParamUnion, error) {
+	switch b.Type {
+	case "text":
+		return anthropic.NewTextBlock(b.Text), nil
+	case "tool_use":
+		return anthropic.ContentBlockParamUnion{OfRequestToolUseBlock: &anthropic.ToolUseBlockParam{
+			ID:    b.ID,
+			Name:  b.Name,
+			Input: b.Input,
+		}}, nil
+	case "tool_result":
+		var texts []string
+		for _, c := range b.Content {
+			texts = append(texts, c.Text)
+		}
+		return anthropic.NewToolResultBlock(b.ToolUseID, strings.Join(texts, ""), b.IsError), nil
+	case "thinking":
+		return anthropic.ContentBlockParamUnion{OfRequestThinkingBlock: &anthropic.ThinkingBlockParam{
+			Thinking:  b.Thinking,
+			Signature: b.Signature,
+		}}, nil
+	case "redacted_thinking":
+		return anthropic.ContentBlockParamUnion{OfRequestRedactedThinkingBlock: &anthropic.RedactedThinkingBlockParam{
+			Data: b.Data,
+		}}, nil
+	default:
+		return anthropic.ContentBlockParamUnion{}, fmt.Errorf("unsupported content block type %q", b.Type)
+	}
+}
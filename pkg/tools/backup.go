@@ -0,0 +1,37 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+)
+
+// Backup makes file-editing tools copy a file to "<path>.bak" before changing it
+var Backup bool
+
+// backupFile copies the file at p to p.bak when Backup is enabled and returns the backup's path.
+// It returns "" if backups are disabled or p doesn't exist yet.
+func backupFile(p string) (string, error) {
+	if !Backup {
+		return "", nil
+	}
+	info, err := FS.Stat(p)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	dst := p + ".bak"
+	if err := copyFile(p, dst, info); err != nil {
+		return "", fmt.Errorf("failed to back up %s: %w", displayPath(p), err)
+	}
+	return dst, nil
+}
+
+// backupNote describes where a backup was saved, for appending to a tool result
+func backupNote(backup string) string {
+	if backup == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (backup saved to %s)", displayPath(backup))
+}
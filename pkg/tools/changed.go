@@ -0,0 +1,44 @@
+package tools
+
+import (
+	"encoding/json"
+	"path/filepath"
+)
+
+// changedInputPaths returns an agent.ToolDefinition.ChangedPaths function that reports the
+// paths named by the given string fields of a tool's input
+func changedInputPaths(fields ...string) func(json.RawMessage, string) []string {
+	return func(input json.RawMessage, _ string) []string {
+		if DryRun {
+			return nil
+		}
+		var values map[string]any
+		if err := json.Unmarshal(input, &values); err != nil {
+			return nil
+		}
+		var paths []string
+		for _, field := range fields {
+			s, _ := values[field].(string)
+			if s == "" {
+				continue
+			}
+			if p, err := resolvePath(s); err == nil {
+				paths = append(paths, rootRelPath(p))
+			}
+		}
+		return paths
+	}
+}
+
+// rootRelPath returns the resolved path p relative to RootDir, so that it stays meaningful after the working directory changes
+func rootRelPath(p string) string {
+	root, err := filepath.Abs(RootDir)
+	if err != nil {
+		return p
+	}
+	rel, err := filepath.Rel(root, p)
+	if err != nil {
+		return p
+	}
+	return rel
+}
@@ -0,0 +1,110 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/MarkusZoppelt/oen/pkg/agent"
+)
+
+// defaultCommandTimeout is used when no timeout is given for run_command
+const defaultCommandTimeout = 60 * time.Second
+
+// maxCommandTimeout is the longest timeout run_command accepts
+const maxCommandTimeout = 10 * time.Minute
+
+// NewRunCommandDefinition returns a run_command tool restricted to the allowed binaries
+func NewRunCommandDefinition(allowed []string) agent.ToolDefinition {
+	return agent.ToolDefinition{
+		Name:        "run_command",
+		Description: fmt.Sprintf("Run a command in the working directory and return its combined stdout/stderr and exit code. Only these commands are permitted: %s.", strings.Join(allowed, ", ")),
+		InputSchema: GenerateSchema[RunCommandInput](),
+		Function: func(ctx context.Context, input json.RawMessage) (string, error) {
+			return RunCommand(ctx, allowed, input)
+		},
+		RequiresConfirmation: true,
+		Category:             agent.CategoryCommand,
+		// Commands enforce their own timeout, which may exceed the default tool timeout.
+		Timeout: maxCommandTimeout + 5*time.Second,
+	}
+}
+
+// RunCommandInput holds input for run_command tool
+type RunCommandInput struct {
+	Command        string   `json:"command" jsonschema_description:"The name of the binary to run. Must be on the allowlist."`
+	Args           []string `json:"args,omitempty" jsonschema_description:"Optional arguments passed to the command."`
+	TimeoutSeconds int      `json:"timeout_seconds,omitempty" jsonschema:"minimum=0,maximum=600" jsonschema_description:"Optional timeout in seconds, at most 600. Defaults to 60."`
+}
+
+// RunCommandResult holds the outcome of a run_command call
+type RunCommandResult struct {
+	ExitCode int    `json:"exit_code"`
+	Output   string `json:"output"`
+}
+
+// RunCommand runs an allowlisted command and reports its output and exit code
+func RunCommand(ctx context.Context, allowed []string, input json.RawMessage) (string, error) {
+	var in RunCommandInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", err
+	}
+	if in.Command == "" {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "command must not be empty")
+	}
+	if !slices.Contains(allowed, in.Command) {
+		return "", agent.NewToolError(agent.ToolErrorPermissionDenied, "command %q is not allowed; permitted commands: %s", in.Command, strings.Join(allowed, ", "))
+	}
+
+	if DryRun {
+		return dryRunf("Would run %s", strings.Join(append([]string{in.Command}, in.Args...), " ")), nil
+	}
+
+	timeout := defaultCommandTimeout
+	if in.TimeoutSeconds > 0 {
+		timeout = min(time.Duration(in.TimeoutSeconds)*time.Second, maxCommandTimeout)
+	}
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	dir, err := WorkDir()
+	if err != nil {
+		return "", err
+	}
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(runCtx, in.Command, in.Args...)
+	cmd.Dir = dir
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	setProcessGroup(cmd)
+
+	err = cmd.Run()
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	if runCtx.Err() == context.DeadlineExceeded {
+		return "", agent.NewToolError(agent.ToolErrorTimeout, "command timed out after %s; output so far:\n%s", timeout, output.String())
+	}
+
+	exitCode := 0
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return "", fmt.Errorf("failed to run command: %w", err)
+		}
+		exitCode = exitErr.ExitCode()
+	}
+
+	result, err := json.Marshal(RunCommandResult{ExitCode: exitCode, Output: output.String()})
+	if err != nil {
+		return "", err
+	}
+	return string(result), nil
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package tools
+
+import (
+	"os/exec"
+	"time"
+)
+
+// setProcessGroup only kills the direct child on platforms without process groups
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.WaitDelay = time.Second
+}
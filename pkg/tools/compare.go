@@ -0,0 +1,77 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/MarkusZoppelt/oen/pkg/agent"
+)
+
+func init() {
+	agent.DefaultRegistry.Register(CompareFilesDefinition)
+}
+
+// CompareFilesDefinition allows diffing two files without reading both
+var CompareFilesDefinition = agent.ToolDefinition{
+	Name:        "compare_files",
+	Description: "Compare two files and return a unified diff from path_a to path_b, or \"identical\" if their contents are equal. Use this instead of reading both files to find out how they differ. Binary files are only reported as equal or different.",
+	InputSchema: GenerateSchema[CompareFilesInput](),
+	Function:    CompareFiles,
+	ReadOnly:    true,
+}
+
+// CompareFilesInput holds input for compare_files tool
+type CompareFilesInput struct {
+	PathA string `json:"path_a" jsonschema_description:"The relative path of the first file, shown as the old side of the diff."`
+	PathB string `json:"path_b" jsonschema_description:"The relative path of the second file, shown as the new side of the diff."`
+}
+
+// CompareFiles returns a unified diff between two files
+func CompareFiles(ctx context.Context, input json.RawMessage) (string, error) {
+	var in CompareFilesInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", err
+	}
+	if in.PathA == "" || in.PathB == "" {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "path_a and path_b must not be empty")
+	}
+
+	a, err := readCompareFile(in.PathA)
+	if err != nil {
+		return "", err
+	}
+	b, err := readCompareFile(in.PathB)
+	if err != nil {
+		return "", err
+	}
+	if bytes.Equal(a, b) {
+		return "identical", nil
+	}
+	if looksBinary(a) || looksBinary(b) {
+		return fmt.Sprintf("Binary files %s and %s differ", in.PathA, in.PathB), nil
+	}
+	diff := unifiedDiffFiles(in.PathA, in.PathB, string(a), string(b))
+	if diff == "" {
+		// The lines only differ in whether the last one ends with a newline.
+		return fmt.Sprintf("%s and %s only differ in the newline at the end of the file", in.PathA, in.PathB), nil
+	}
+	return diff, nil
+}
+
+// readCompareFile reads the file at the relative path name for compare_files
+func readCompareFile(name string) ([]byte, error) {
+	p, err := resolvePath(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := FS.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, agent.NewToolError(agent.ToolErrorInvalidInput, "%s is a directory", name)
+	}
+	return FS.ReadFile(p)
+}
@@ -0,0 +1,152 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"unicode"
+
+	"github.com/MarkusZoppelt/oen/pkg/agent"
+)
+
+// maxCountFiles is the most files count takes in one call
+const maxCountFiles = 1000
+
+func init() {
+	agent.DefaultRegistry.Register(CountDefinition)
+}
+
+// CountDefinition allows counting the lines, words, bytes, and characters of files
+var CountDefinition = agent.ToolDefinition{
+	Name:        "count",
+	Description: "Count the lines, words, bytes, and characters of a file, like wc, without reading it. Pass pattern instead of path to count every file matching a glob, e.g. **/*.go, with a total.",
+	InputSchema: GenerateSchema[CountInput](),
+	Function:    Count,
+	ReadOnly:    true,
+}
+
+// CountInput holds input for count tool
+type CountInput struct {
+	Path    string `json:"path,omitempty" jsonschema_description:"The relative path of a file to count."`
+	Pattern string `json:"pattern,omitempty" jsonschema_description:"A glob relative to the working directory, with / as separator, to count all matching files instead. ** matches any number of directories."`
+}
+
+// CountResult holds the counts of a file, or the totals of several
+type CountResult struct {
+	Path  string `json:"path,omitempty"`
+	Lines int64  `json:"lines"`
+	Words int64  `json:"words"`
+	Bytes int64  `json:"bytes"`
+	Chars int64  `json:"chars"`
+}
+
+// CountTotal holds the counts of the files matching a pattern
+type CountTotal struct {
+	Files []CountResult `json:"files"`
+	Total CountResult   `json:"total"`
+}
+
+// Count counts the lines, words, bytes, and characters of a file or the files matching a glob
+func Count(ctx context.Context, input json.RawMessage) (string, error) {
+	var in CountInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", err
+	}
+	if (in.Path == "") == (in.Pattern == "") {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "pass either path or pattern")
+	}
+
+	var result any
+	if in.Path != "" {
+		p, err := resolvePath(in.Path)
+		if err != nil {
+			return "", err
+		}
+		counts, err := countFile(p)
+		if err != nil {
+			return "", err
+		}
+		counts.Path = in.Path
+		result = counts
+	} else {
+		matches, err := globFiles(ctx, in.Pattern)
+		if err != nil {
+			return "", err
+		}
+		if len(matches) > maxCountFiles {
+			return "", agent.NewToolError(agent.ToolErrorInvalidInput, "%s matches %d files, but at most %d can be counted at once; use a narrower pattern", in.Pattern, len(matches), maxCountFiles)
+		}
+		total := CountTotal{Files: make([]CountResult, 0, len(matches))}
+		for _, p := range matches {
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			counts, err := countFile(p)
+			if err != nil {
+				return "", err
+			}
+			counts.Path = displayPath(p)
+			total.Files = append(total.Files, counts)
+			total.Total.Lines += counts.Lines
+			total.Total.Words += counts.Words
+			total.Total.Bytes += counts.Bytes
+			total.Total.Chars += counts.Chars
+		}
+		result = total
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// countFile counts the file at p. A last line without a newline counts as a line, and a character
+// is a UTF-8 encoded rune or an invalid byte.
+func countFile(p string) (CountResult, error) {
+	var counts CountResult
+	f, err := FS.Open(p)
+	if err != nil {
+		return counts, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return counts, err
+	}
+	if info.IsDir() {
+		return counts, agent.NewToolError(agent.ToolErrorInvalidInput, "%s is a directory", displayPath(p))
+	}
+
+	reader := bufio.NewReader(f)
+	inWord := false
+	last := '\n'
+	for {
+		r, size, err := reader.ReadRune()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return counts, fmt.Errorf("failed to read %s: %w", displayPath(p), err)
+		}
+		counts.Bytes += int64(size)
+		counts.Chars++
+		if r == '\n' {
+			counts.Lines++
+		}
+		if unicode.IsSpace(r) {
+			inWord = false
+		} else if !inWord {
+			inWord = true
+			counts.Words++
+		}
+		last = r
+	}
+	if last != '\n' {
+		counts.Lines++
+	}
+	return counts, nil
+}
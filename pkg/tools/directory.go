@@ -1,19 +1,32 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"slices"
 
 	"github.com/MarkusZoppelt/oen/pkg/agent"
 )
 
+func init() {
+	agent.DefaultRegistry.Register(MakeDirectoryDefinition)
+	agent.DefaultRegistry.Register(RemoveDirectoryDefinition)
+	agent.DefaultRegistry.Register(RenameDirectoryDefinition)
+	agent.DefaultRegistry.Register(ChangeDirectoryDefinition)
+}
+
 // MakeDirectoryDefinition allows creating directories recursively
 var MakeDirectoryDefinition = agent.ToolDefinition{
-	Name:        "make_directory",
-	Description: "Create a new directory at the given relative path, creating parent directories as needed.",
-	InputSchema: GenerateSchema[MakeDirectoryInput](),
-	Function:    MakeDirectory,
+	Name:         "make_directory",
+	Description:  "Create a new directory at the given relative path, creating parent directories as needed.",
+	InputSchema:  GenerateSchema[MakeDirectoryInput](),
+	Function:     MakeDirectory,
+	ChangedPaths: changedInputPaths("path"),
 }
 
 // MakeDirectoryInput holds input for make_directory tool
@@ -22,15 +35,27 @@ type MakeDirectoryInput struct {
 }
 
 // MakeDirectory creates a directory and any necessary parents
-func MakeDirectory(input json.RawMessage) (string, error) {
+func MakeDirectory(ctx context.Context, input json.RawMessage) (string, error) {
 	var in MakeDirectoryInput
 	if err := json.Unmarshal(input, &in); err != nil {
 		return "", err
 	}
 	if in.Path == "" {
-		return "", fmt.Errorf("path must not be empty")
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "path must not be empty")
+	}
+	p, err := resolvePath(in.Path)
+	if err != nil {
+		return "", err
 	}
-	if err := os.MkdirAll(in.Path, 0755); err != nil {
+	if DryRun {
+		return dryRunf("Would create directory %s", in.Path), nil
+	}
+	if missing := missingDirs(p); len(missing) > 0 {
+		if err := recordUndo(missing...); err != nil {
+			return "", err
+		}
+	}
+	if err := FS.MkdirAll(p, 0755); err != nil {
 		return "", fmt.Errorf("failed to create directory: %w", err)
 	}
 	return fmt.Sprintf("Successfully created directory %s", in.Path), nil
@@ -38,45 +63,177 @@ func MakeDirectory(input json.RawMessage) (string, error) {
 
 // RemoveDirectoryDefinition allows removing directories
 var RemoveDirectoryDefinition = agent.ToolDefinition{
-	Name:        "remove_directory",
-	Description: "Remove a directory at the given relative path. If recursive is true, remove all contents recursively; otherwise, only if empty.",
-	InputSchema: GenerateSchema[RemoveDirectoryInput](),
-	Function:    RemoveDirectory,
+	Name:                 "remove_directory",
+	Description:          "Remove a directory at the given relative path. If recursive is true, remove all contents recursively; otherwise, only if empty. A recursive remove of a directory with many entries fails with their count unless force is true, so check the path before forcing it.",
+	InputSchema:          GenerateSchema[RemoveDirectoryInput](),
+	Function:             RemoveDirectory,
+	RequiresConfirmation: true,
+	Category:             agent.CategoryDelete,
+	ChangedPaths:         changedInputPaths("path"),
 }
 
 // RemoveDirectoryInput holds input for remove_directory tool
 type RemoveDirectoryInput struct {
 	Path      string `json:"path" jsonschema_description:"The relative path of the directory to remove."`
-	Recursive bool   `json:"recursive" jsonschema_description:"Whether to remove directory recursively along with its contents."`
+	Recursive bool   `json:"recursive,omitempty" jsonschema_description:"Whether to remove directory recursively along with its contents."`
+	Force     bool   `json:"force,omitempty" jsonschema_description:"Whether to remove the directory recursively even if it has more entries than the safety limit."`
 }
 
+// DefaultMaxRemoveEntries is the default of MaxRemoveEntries
+const DefaultMaxRemoveEntries = 1000
+
+// MaxRemoveEntries is the most files and directories a recursive remove_directory deletes without force.
+// It guards against wiping out a large tree through a mistyped path; zero or less turns the guard off.
+var MaxRemoveEntries = DefaultMaxRemoveEntries
+
 // RemoveDirectory removes a directory based on input
-func RemoveDirectory(input json.RawMessage) (string, error) {
+func RemoveDirectory(ctx context.Context, input json.RawMessage) (string, error) {
 	var in RemoveDirectoryInput
 	if err := json.Unmarshal(input, &in); err != nil {
 		return "", err
 	}
 	if in.Path == "" {
-		return "", fmt.Errorf("path must not be empty")
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "path must not be empty")
+	}
+	p, err := resolvePath(in.Path)
+	if err != nil {
+		return "", err
+	}
+	root, err := isRoot(p)
+	if err != nil {
+		return "", err
+	}
+	if root {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "not removing %s: it is a workspace root", in.Path)
+	}
+	if err := checkIgnoredTree(ctx, p, "", in.Path); err != nil {
+		return "", err
+	}
+	if DryRun {
+		if _, err := FS.Stat(p); err != nil {
+			return "", err
+		}
+		if in.Recursive {
+			entries, err := countEntries(ctx, p)
+			if err != nil {
+				return "", err
+			}
+			return dryRunf("Would remove directory %s and the %d entries in it", in.Path, entries), nil
+		}
+		return dryRunf("Would remove directory %s", in.Path), nil
+	}
+	if Trash {
+		if !in.Recursive {
+			entries, err := FS.ReadDir(p)
+			if err != nil {
+				return "", err
+			}
+			if len(entries) > 0 {
+				return "", fmt.Errorf("failed to remove directory: %s is not empty, set recursive to remove its contents", in.Path)
+			}
+		}
+		trashed, err := moveToTrash(p)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Moved directory %s to the trash at %s", in.Path, displayPath(trashed)), nil
 	}
 	if in.Recursive {
-		if err := os.RemoveAll(in.Path); err != nil {
+		// The trash keeps what it is given, so only removing for good is guarded.
+		if MaxRemoveEntries > 0 && !in.Force {
+			entries, err := countEntries(ctx, p)
+			if err != nil {
+				return "", err
+			}
+			if entries > MaxRemoveEntries {
+				return "", agent.NewToolError(agent.ToolErrorInvalidInput, "not removing %s: it contains %d files and directories, more than the limit of %d. Check that this is the right directory, then set force to remove it", in.Path, entries, MaxRemoveEntries)
+			}
+		}
+	}
+	// The tree is only read into memory if undo or a rollback could restore it.
+	var snapshots []fileSnapshot
+	recorded := RecordUndo || DefaultJournal.Active()
+	if recorded {
+		snapshots, err = snapshotTree(ctx, p)
+		switch {
+		case errors.Is(err, errTreeTooLarge) && DefaultJournal.Active():
+			return "", agent.NewToolError(agent.ToolErrorInvalidInput, "not removing %s: it is too large to be restored if the response is rolled back. Ask the user to run oeN with --trash to remove it recoverably", in.Path)
+		case errors.Is(err, errTreeTooLarge):
+			recorded = false
+		case err != nil:
+			return "", err
+		}
+	}
+	if in.Recursive {
+		if err := FS.RemoveAll(p); err != nil {
+			// Part of the tree may be gone, and the snapshots can bring it back.
+			if recorded {
+				pushUndo(snapshots)
+			}
 			return "", fmt.Errorf("failed to remove directory recursively: %w", err)
 		}
 	} else {
-		if err := os.Remove(in.Path); err != nil {
+		if err := FS.Remove(p); err != nil {
 			return "", fmt.Errorf("failed to remove directory: %w", err)
 		}
 	}
+	if !recorded {
+		if RecordUndo {
+			return fmt.Sprintf("Successfully removed directory %s. It was too large to keep for undo, so it can't be undone; run oeN with --trash to remove large directories recoverably", in.Path), nil
+		}
+		return fmt.Sprintf("Successfully removed directory %s", in.Path), nil
+	}
+	pushUndo(snapshots)
 	return fmt.Sprintf("Successfully removed directory %s", in.Path), nil
 }
 
+// checkIgnoredTree returns an error naming the directory p as name if anything below it is ignored,
+// under its name or under dest if it is moved there
+func checkIgnoredTree(ctx context.Context, p, dest, name string) error {
+	ignore, err := loadIgnoreRules()
+	if err != nil {
+		return err
+	}
+	return ignore.checkTree(ctx, p, dest, name)
+}
+
+// missingDirs returns the directories that creating the directory p would create, parents first
+func missingDirs(p string) []string {
+	var missing []string
+	for ; ; p = filepath.Dir(p) {
+		if _, err := FS.Lstat(p); !os.IsNotExist(err) || filepath.Dir(p) == p {
+			break
+		}
+		missing = append(missing, p)
+	}
+	slices.Reverse(missing)
+	return missing
+}
+
+// countEntries returns the number of files and directories below the directory p, without following symlinks
+func countEntries(ctx context.Context, p string) (int, error) {
+	entries := -1
+	err := walkDir(p, func(_ string, _ fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+		entries++
+		return nil
+	})
+	return max(entries, 0), err
+}
+
 // RenameDirectoryDefinition allows renaming or moving directories
 var RenameDirectoryDefinition = agent.ToolDefinition{
-	Name:        "rename_directory",
-	Description: "Rename or move a directory from the old path to the new path.",
-	InputSchema: GenerateSchema[RenameDirectoryInput](),
-	Function:    RenameDirectory,
+	Name:                 "rename_directory",
+	Description:          "Rename or move a directory from the old path to the new path.",
+	InputSchema:          GenerateSchema[RenameDirectoryInput](),
+	Function:             RenameDirectory,
+	RequiresConfirmation: true,
+	ChangedPaths:         changedInputPaths("old_path", "new_path"),
 }
 
 // RenameDirectoryInput holds input for rename_directory tool
@@ -86,16 +243,66 @@ type RenameDirectoryInput struct {
 }
 
 // RenameDirectory renames or moves a directory
-func RenameDirectory(input json.RawMessage) (string, error) {
+func RenameDirectory(ctx context.Context, input json.RawMessage) (string, error) {
 	var in RenameDirectoryInput
 	if err := json.Unmarshal(input, &in); err != nil {
 		return "", err
 	}
 	if in.OldPath == "" || in.NewPath == "" {
-		return "", fmt.Errorf("old_path and new_path must not be empty")
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "old_path and new_path must not be empty")
+	}
+	oldPath, err := resolvePath(in.OldPath)
+	if err != nil {
+		return "", err
+	}
+	newPath, err := resolvePath(in.NewPath)
+	if err != nil {
+		return "", err
+	}
+	// Ignored files must not become reachable under the new name, nor be moved by the model at all.
+	if err := checkIgnoredTree(ctx, oldPath, newPath, in.OldPath); err != nil {
+		return "", err
 	}
-	if err := os.Rename(in.OldPath, in.NewPath); err != nil {
+	if DryRun {
+		if _, err := FS.Stat(oldPath); err != nil {
+			return "", err
+		}
+		return dryRunf("Would rename directory from %s to %s", in.OldPath, in.NewPath), nil
+	}
+	if err := FS.Rename(oldPath, newPath); err != nil {
 		return "", fmt.Errorf("failed to rename directory: %w", err)
 	}
+	recordMove(oldPath, newPath)
 	return fmt.Sprintf("Successfully renamed directory from %s to %s", in.OldPath, in.NewPath), nil
 }
+
+// ChangeDirectoryDefinition allows changing the directory relative paths are resolved against
+var ChangeDirectoryDefinition = agent.ToolDefinition{
+	Name:        "change_directory",
+	Description: "Change the working directory that all relative paths in later tool calls are resolved against, like cd. Paths stay confined to the directory oeN was started in and the other workspace roots. Returns the new absolute working directory.",
+	InputSchema: GenerateSchema[ChangeDirectoryInput](),
+	Function:    ChangeDirectory,
+	// It changes state but not files, so it counts as reading.
+	Category: agent.CategoryRead,
+}
+
+// ChangeDirectoryInput holds input for change_directory tool
+type ChangeDirectoryInput struct {
+	Path string `json:"path" jsonschema_description:"The relative path of the new working directory, e.g. a subdirectory or .. to go up."`
+}
+
+// ChangeDirectory changes the working directory of the tools
+func ChangeDirectory(ctx context.Context, input json.RawMessage) (string, error) {
+	var in ChangeDirectoryInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", err
+	}
+	if in.Path == "" {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "path must not be empty")
+	}
+	dir, err := SetWorkDir(in.Path)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Working directory is now %s", dir), nil
+}
@@ -0,0 +1,197 @@
+package tools
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/MarkusZoppelt/oen/pkg/agent"
+)
+
+func TestDirectoryToolsKeepIgnoredFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		call    func(t *testing.T) (string, error)
+		ignored bool
+	}{
+		{
+			name: "rename directory with ignored file",
+			call: func(t *testing.T) (string, error) {
+				return callTool(t, RenameDirectory, RenameDirectoryInput{OldPath: "keys", NewPath: "moved"})
+			},
+			ignored: true,
+		},
+		{
+			name: "rename directory onto ignored name",
+			call: func(t *testing.T) (string, error) {
+				return callTool(t, RenameDirectory, RenameDirectoryInput{OldPath: "src", NewPath: "private"})
+			},
+			ignored: true,
+		},
+		{
+			name: "rename directory whose files become ignored",
+			call: func(t *testing.T) (string, error) {
+				return callTool(t, RenameDirectory, RenameDirectoryInput{OldPath: "src", NewPath: "build"})
+			},
+			ignored: true,
+		},
+		{
+			name: "rename directory",
+			call: func(t *testing.T) (string, error) {
+				return callTool(t, RenameDirectory, RenameDirectoryInput{OldPath: "src", NewPath: "lib"})
+			},
+		},
+		{
+			name: "remove directory with ignored file",
+			call: func(t *testing.T) (string, error) {
+				return callTool(t, RemoveDirectory, RemoveDirectoryInput{Path: "keys", Recursive: true})
+			},
+			ignored: true,
+		},
+		{
+			name: "remove ignored directory",
+			call: func(t *testing.T) (string, error) {
+				return callTool(t, RemoveDirectory, RemoveDirectoryInput{Path: "private", Recursive: true})
+			},
+			ignored: true,
+		},
+		{
+			name: "remove directory",
+			call: func(t *testing.T) (string, error) {
+				return callTool(t, RemoveDirectory, RemoveDirectoryInput{Path: "src", Recursive: true})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMemFS(t)
+			writeFiles(t, map[string]string{
+				IgnoreFileName:     "*.key\nprivate\nbuild/*.go\n",
+				"keys/id.key":      "secret",
+				"keys/README":      "keys",
+				"private/notes.md": "notes",
+				"src/main.go":      "package main",
+			})
+			_, err := tt.call(t)
+			var toolErr *agent.ToolError
+			if tt.ignored && !(errors.As(err, &toolErr) && toolErr.Code == agent.ToolErrorIgnored) {
+				t.Fatalf("err = %v, want an ignored error", err)
+			}
+			if !tt.ignored && err != nil {
+				t.Fatalf("err = %v, want no error", err)
+			}
+			if tt.ignored && readFile(t, "keys/id.key") != "secret" {
+				t.Error("ignored file changed")
+			}
+		})
+	}
+}
+
+func TestConfigFileIsProtected(t *testing.T) {
+	useMemFS(t)
+	writeFiles(t, map[string]string{"oen.yaml": "max_turns: 5\n"})
+
+	if _, err := callTool(t, ReadFile, ReadFileInput{Path: "oen.yaml"}); err == nil {
+		t.Error("read_file oen.yaml succeeded, want an error")
+	}
+	if _, err := callTool(t, WriteFile, WriteFileInput{Path: "oen.yaml", Content: "max_turns: 500\n"}); err == nil {
+		t.Error("write_file oen.yaml succeeded, want an error")
+	}
+	if _, err := callTool(t, RemoveDirectory, RemoveDirectoryInput{Path: ".", Recursive: true}); err == nil {
+		t.Error("remove_directory . succeeded, want an error")
+	}
+	if got := readFile(t, "oen.yaml"); got != "max_turns: 5\n" {
+		t.Errorf("oen.yaml = %q, want it unchanged", got)
+	}
+}
+
+func TestRemoveDirectoryUndoRecord(t *testing.T) {
+	tests := []struct {
+		name         string
+		recordUndo   bool
+		journal      bool
+		large        bool
+		wantErr      bool
+		wantRecorded bool
+	}{
+		{name: "undo", recordUndo: true, wantRecorded: true},
+		{name: "undo off", recordUndo: false},
+		{name: "undo off in a transaction", journal: true, wantRecorded: true},
+		{name: "too large for undo", recordUndo: true, large: true},
+		{name: "too large for a transaction", recordUndo: true, journal: true, large: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMemFS(t)
+			files := map[string]string{"src/main.go": "package main"}
+			if tt.large {
+				files["src/data.bin"] = strings.Repeat("x", maxTreeSnapshotBytes)
+			}
+			writeFiles(t, files)
+			oldStack, oldRecord, oldJournal := undoStack, RecordUndo, DefaultJournal
+			undoStack, RecordUndo, DefaultJournal = nil, tt.recordUndo, &Journal{}
+			t.Cleanup(func() { undoStack, RecordUndo, DefaultJournal = oldStack, oldRecord, oldJournal })
+			if tt.journal {
+				DefaultJournal.Begin()
+			}
+
+			out, err := callTool(t, RemoveDirectory, RemoveDirectoryInput{Path: "src", Recursive: true})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("remove_directory succeeded, want an error")
+				}
+				if readFile(t, "src/main.go") != "package main" {
+					t.Error("src/main.go was removed")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.large && !strings.Contains(out, "--trash") {
+				t.Errorf("output = %q, want it to mention --trash", out)
+			}
+			restored, err := DefaultJournal.Rollback()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if recorded := len(undoStack) > 0 || len(restored) > 0; recorded != tt.wantRecorded {
+				t.Errorf("recorded = %v, want %v", recorded, tt.wantRecorded)
+			}
+		})
+	}
+}
+
+func TestRemoveDirectoryKeepsRoots(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+	}{
+		{name: "dot", path: "."},
+		{name: "dot dot inside", path: "src/.."},
+		{name: "absolute root", path: "ROOT"},
+		{name: "workspace root", path: "../other"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMemFS(t)
+			other := filepath.Join(filepath.Dir(RootDir), "other")
+			WorkspaceRoots = []string{other}
+			writeFiles(t, map[string]string{"src/main.go": "package main", "../other/lib.go": "package lib"})
+
+			path := strings.Replace(tt.path, "ROOT", RootDir, 1)
+			_, err := callTool(t, RemoveDirectory, RemoveDirectoryInput{Path: path, Recursive: true, Force: true})
+			var toolErr *agent.ToolError
+			if !errors.As(err, &toolErr) || toolErr.Code != agent.ToolErrorInvalidInput {
+				t.Fatalf("err = %v, want an invalid input error", err)
+			}
+			if readFile(t, "src/main.go") != "package main" || readFile(t, "../other/lib.go") != "package lib" {
+				t.Error("a root was changed")
+			}
+		})
+	}
+}
@@ -0,0 +1,11 @@
+package tools
+
+import "fmt"
+
+// DryRun makes file-mutating tools report the intended change without applying it
+var DryRun bool
+
+// dryRunf formats a message describing a change that was skipped because of DryRun
+func dryRunf(format string, args ...any) string {
+	return "[DRY RUN] " + fmt.Sprintf(format, args...)
+}
@@ -0,0 +1,116 @@
+package tools
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/MarkusZoppelt/oen/pkg/agent"
+)
+
+// duLargestFiles is the number of largest files disk_usage lists
+const duLargestFiles = 5
+
+func init() {
+	agent.DefaultRegistry.Register(DiskUsageDefinition)
+}
+
+// DiskUsageDefinition allows measuring how big a directory is
+var DiskUsageDefinition = agent.ToolDefinition{
+	Name:        "disk_usage",
+	Description: "Report the total size and number of files under a relative path, along with the largest files. Use this to check how big a directory is before listing, searching, or reading it. Like directory_tree, it skips .git and doesn't follow symbolic links.",
+	InputSchema: GenerateSchema[DiskUsageInput](),
+	Function:    DiskUsage,
+	ReadOnly:    true,
+}
+
+// DiskUsageInput holds input for disk_usage tool
+type DiskUsageInput struct {
+	Path string `json:"path,omitempty" jsonschema_description:"Optional relative path of the directory to measure. Defaults to current directory if not provided."`
+}
+
+// duFile is a file counted by disk_usage
+type duFile struct {
+	path string
+	size int64
+}
+
+// DiskUsage sums the sizes of the files under a directory
+func DiskUsage(ctx context.Context, input json.RawMessage) (string, error) {
+	var in DiskUsageInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", err
+	}
+	dir, err := resolvePath(in.Path)
+	if err != nil {
+		return "", err
+	}
+	if _, err := FS.Stat(dir); err != nil {
+		return "", err
+	}
+
+	ignore, err := loadIgnoreRules()
+	if err != nil {
+		return "", err
+	}
+
+	var total int64
+	var files []duFile
+	err = walkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+		if p != dir && ignore.matches(p) {
+			return skipEntry(d.IsDir())
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" && p != dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			// path is a single file
+			rel = d.Name()
+		}
+		total += info.Size()
+		files = append(files, duFile{rel, info.Size()})
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s (%d bytes) in %d files under %s\n", formatSize(total), total, len(files), displayPath(dir))
+	if len(files) == 0 {
+		return sb.String(), nil
+	}
+	slices.SortFunc(files, func(a, b duFile) int {
+		return cmp.Or(cmp.Compare(b.size, a.size), cmp.Compare(a.path, b.path))
+	})
+	sb.WriteString("Largest files:\n")
+	for _, f := range files[:min(duLargestFiles, len(files))] {
+		fmt.Fprintf(&sb, "%10s  %s\n", formatSize(f.size), f.path)
+	}
+	return sb.String(), nil
+}
@@ -1,75 +1,331 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
 
 	"github.com/MarkusZoppelt/oen/pkg/agent"
 )
 
+func init() {
+	agent.DefaultRegistry.Register(ReadFileDefinition)
+	agent.DefaultRegistry.Register(ReadFileLinesDefinition)
+	agent.DefaultRegistry.Register(ListFilesDefinition)
+	agent.DefaultRegistry.Register(FileInfoDefinition)
+	agent.DefaultRegistry.Register(EditFileDefinition)
+	agent.DefaultRegistry.Register(WriteFileDefinition)
+	agent.DefaultRegistry.Register(ReplaceLinesDefinition)
+	agent.DefaultRegistry.Register(AppendFileDefinition)
+	agent.DefaultRegistry.Register(MoveFileDefinition)
+	agent.DefaultRegistry.Register(CopyFileDefinition)
+	agent.DefaultRegistry.Register(ChmodDefinition)
+	agent.DefaultRegistry.Register(SymlinkDefinition)
+}
+
 // ReadFileDefinition allows reading file contents
 var ReadFileDefinition = agent.ToolDefinition{
 	Name:        "read_file",
-	Description: "Read the contents of a given relative file path. Use this when you want to see what's inside a file. Do not use this with directory names.",
+	Description: "Read the contents of a given relative file path. Use this when you want to see what's inside a file. Do not use this with directory names. To preview a large file, pass max_bytes to read only its start, or max_tokens to read as many whole lines as fit in a token budget.",
 	InputSchema: GenerateSchema[ReadFileInput](),
 	Function:    ReadFile,
+	ReadOnly:    true,
 }
 
 // ReadFileInput holds input for read_file tool
 type ReadFileInput struct {
-	Path string `json:"path" jsonschema_description:"The relative path of a file in the working directory."`
+	Path            string `json:"path" jsonschema_description:"The relative path of a file in the working directory."`
+	WithLineNumbers bool   `json:"with_line_numbers,omitempty" jsonschema_description:"Whether to prefix each line with its 1-based line number and a tab. Useful before replace_lines; leave off when copying text into edit_file."`
+	MaxBytes        int64  `json:"max_bytes,omitempty" jsonschema:"minimum=0" jsonschema_description:"Optional maximum number of bytes to read from the start of the file, to preview a large file cheaply. Reads the whole file if not provided."`
+	MaxTokens       int64  `json:"max_tokens,omitempty" jsonschema:"minimum=0" jsonschema_description:"Optional maximum number of tokens to return, estimated. The output is cut at the end of the last whole line that fits."`
 }
 
 // ReadFileInputSchema holds the schema for read_file input
 var ReadFileInputSchema = GenerateSchema[ReadFileInput]()
 
 // ReadFile reads the contents of a file
-func ReadFile(input json.RawMessage) (string, error) {
+func ReadFile(ctx context.Context, input json.RawMessage) (string, error) {
 	var in ReadFileInput
 	if err := json.Unmarshal(input, &in); err != nil {
 		return "", err
 	}
-	content, err := os.ReadFile(in.Path)
+	p, err := resolvePath(in.Path)
+	if err != nil {
+		return "", err
+	}
+	info, err := FS.Stat(p)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "%s is a directory, use list_files instead", in.Path)
+	}
+	if in.MaxBytes < 0 || in.MaxTokens < 0 {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "max_bytes and max_tokens must not be negative")
+	}
+	if in.MaxBytes > 0 && in.MaxTokens > 0 {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "pass either max_bytes or max_tokens, not both")
+	}
+	if in.MaxBytes > 0 && info.Size() > in.MaxBytes {
+		return readFileHead(p, in.MaxBytes, info.Size(), in.WithLineNumbers)
+	}
+	content, err := FS.ReadFile(p)
+	if err != nil {
+		return "", err
+	}
+	text := string(content)
+	if in.WithLineNumbers {
+		text = numberLines(splitLines(text), 1)
+	}
+	if in.MaxTokens > 0 {
+		return truncateTokens(text, in.MaxTokens), nil
+	}
+	return text, nil
+}
+
+// CountTokens estimates the number of tokens in a text, for tools that limit their output by tokens.
+// It defaults to the estimate the agent uses to measure the conversation and can be replaced by a real tokenizer.
+var CountTokens = agent.EstimateTextTokens
+
+// truncateTokens cuts text after the last whole line whose end is within maxTokens tokens, as counted
+// by CountTokens, and notes how much was left out. A first line that doesn't fit is cut mid-line.
+func truncateTokens(text string, maxTokens int64) string {
+	total := CountTokens(text)
+	if total <= maxTokens {
+		return text
+	}
+	// Find the longest prefix within the budget, assuming longer text never has fewer tokens.
+	lo, hi := 0, len(text)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if CountTokens(text[:mid]) <= maxTokens {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	cut := lo
+	if i := strings.LastIndexByte(text[:cut], '\n'); i >= 0 {
+		cut = i + 1
+	} else {
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+	}
+
+	head := text[:cut]
+	tokens := CountTokens(head)
+	shown := strings.Count(head, "\n")
+	if !strings.HasSuffix(head, "\n") {
+		head += "\n"
+	}
+	return fmt.Sprintf("%s[truncated to about %d tokens, %d of %d lines shown, about %d more tokens]",
+		head, tokens, shown, len(splitLines(text)), total-tokens)
+}
+
+// readFileHead reads at most maxBytes from the start of the file at p, whose size is size,
+// and notes how much was left out. It stops before a character that would be cut in half.
+func readFileHead(p string, maxBytes, size int64, withLineNumbers bool) (string, error) {
+	f, err := FS.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head := make([]byte, maxBytes)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	head = head[:n]
+	for cut := len(head); cut > 0 && cut > len(head)-utf8.UTFMax; cut-- {
+		if utf8.RuneStart(head[cut-1]) {
+			if !utf8.FullRune(head[cut-1:]) {
+				head = head[:cut-1]
+			}
+			break
+		}
+	}
+
+	text := string(head)
+	if withLineNumbers {
+		text = numberLines(splitLines(text), 1)
+	} else if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return fmt.Sprintf("%s[truncated, %d more bytes]", text, size-int64(len(head))), nil
+}
+
+// defaultLineCount is the number of lines read_file_lines returns when no count is given
+const defaultLineCount = 200
+
+// ReadFileLinesDefinition allows reading a range of lines from a file
+var ReadFileLinesDefinition = agent.ToolDefinition{
+	Name:        "read_file_lines",
+	Description: "Read a range of lines from a file, each prefixed with its line number, along with the file's total line count. Use this instead of read_file for large files, paging through them with start_line.",
+	InputSchema: GenerateSchema[ReadFileLinesInput](),
+	Function:    ReadFileLines,
+	ReadOnly:    true,
+}
+
+// ReadFileLinesInput holds input for read_file_lines tool
+type ReadFileLinesInput struct {
+	Path      string `json:"path" jsonschema_description:"The relative path of a file in the working directory."`
+	StartLine int    `json:"start_line" jsonschema:"minimum=1" jsonschema_description:"The first line to return, starting at 1."`
+	LineCount int    `json:"line_count,omitempty" jsonschema:"minimum=1" jsonschema_description:"Optional number of lines to return. Defaults to 200."`
+}
+
+// ReadFileLines returns a numbered range of lines from a file
+func ReadFileLines(ctx context.Context, input json.RawMessage) (string, error) {
+	var in ReadFileLinesInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", err
+	}
+	if in.StartLine < 1 {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "start_line must be at least 1")
+	}
+	lineCount := defaultLineCount
+	if in.LineCount > 0 {
+		lineCount = in.LineCount
+	}
+
+	p, err := resolvePath(in.Path)
+	if err != nil {
+		return "", err
+	}
+	info, err := FS.Stat(p)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "%s is a directory, use list_files instead", in.Path)
+	}
+	content, err := FS.ReadFile(p)
 	if err != nil {
 		return "", err
 	}
-	return string(content), nil
+
+	lines := splitLines(string(content))
+	if in.StartLine > len(lines) {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "start_line %d is past the end of %s, which has %d lines", in.StartLine, in.Path, len(lines))
+	}
+	end := min(in.StartLine-1+lineCount, len(lines))
+	return fmt.Sprintf("Lines %d-%d of %d in %s:\n%s", in.StartLine, end, len(lines), in.Path, numberLines(lines[in.StartLine-1:end], in.StartLine)), nil
+}
+
+// numberLines joins lines, prefixing each with its line number counting from first
+func numberLines(lines []string, first int) string {
+	var sb strings.Builder
+	for i, line := range lines {
+		fmt.Fprintf(&sb, "%6d\t%s\n", first+i, line)
+	}
+	return sb.String()
 }
 
 // ListFilesDefinition allows listing files in a directory
 var ListFilesDefinition = agent.ToolDefinition{
 	Name:        "list_files",
-	Description: "List files and directories at a given path. If no path is provided, lists files in the current directory.",
+	Description: "List files and directories at a given path. If no path is provided, lists files in the current directory. Directories end in / and symbolic links end in @. Returns a JSON array unless another format is given. If the workspace has several roots, all_roots lists them all.",
 	InputSchema: GenerateSchema[ListFilesInput](),
 	Function:    ListFiles,
+	ReadOnly:    true,
 }
 
 // ListFilesInput holds input for list_files tool
 type ListFilesInput struct {
-	Path string `json:"path,omitempty" jsonschema_description:"Optional relative path to list files from. Defaults to current directory if not provided."`
+	Path     string `json:"path,omitempty" jsonschema_description:"Optional relative path to list files from. Defaults to current directory if not provided."`
+	MaxDepth int    `json:"max_depth,omitempty" jsonschema:"minimum=0" jsonschema_description:"Optional maximum directory depth to descend into, where 1 lists only direct children. Unlimited if not provided."`
+	Limit    int    `json:"limit,omitempty" jsonschema:"minimum=0" jsonschema_description:"Optional maximum number of entries to return. Unlimited if not provided."`
+	Type     string `json:"type,omitempty" jsonschema:"enum=all,enum=files,enum=dirs" jsonschema_description:"Optional filter: only files, only directories, or all entries. Defaults to all."`
+	// ResolveLinks appends the target of each symlink, e.g. "config@ -> ../shared/config"
+	ResolveLinks bool   `json:"resolve_links,omitempty" jsonschema_description:"Optional: show the target of each symbolic link."`
+	AllRoots     bool   `json:"all_roots,omitempty" jsonschema_description:"Optional: list every workspace root instead of path, with paths relative to the working directory."`
+	Format       string `json:"format,omitempty" jsonschema:"enum=json,enum=flat,enum=tree" jsonschema_description:"Optional output format: a JSON array, flat with one path per line, or an indented tree that names each directory once. Defaults to json."`
 }
 
 // ListFilesInputSchema holds the schema for list_files input
 var ListFilesInputSchema = GenerateSchema[ListFilesInput]()
 
 // ListFiles lists files and directories
-func ListFiles(input json.RawMessage) (string, error) {
+func ListFiles(ctx context.Context, input json.RawMessage) (string, error) {
 	var in ListFilesInput
 	if err := json.Unmarshal(input, &in); err != nil {
 		return "", err
 	}
+	switch in.Format {
+	case "", "json", "flat", "tree":
+	default:
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "unknown format %q, use json, flat, or tree", in.Format)
+	}
 
-	dir := "."
-	if in.Path != "" {
-		dir = in.Path
+	if in.AllRoots && in.Path != "" {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "all_roots lists every workspace root, leave path out")
+	}
+	dir, err := resolvePath(in.Path)
+	if err != nil {
+		return "", err
+	}
+	dirs := []string{dir}
+	if in.AllRoots {
+		if dirs, err = roots(); err != nil {
+			return "", err
+		}
+	}
+
+	ignore, err := loadIgnoreRules()
+	if err != nil {
+		return "", err
 	}
 
 	var files []string
-	err := filepath.Walk(dir, func(pathStr string, info os.FileInfo, err error) error {
+	truncated := false
+	for i, root := range dirs {
+		// A root nested in another has been listed with it.
+		if insideRoots(dirs[:i], root) {
+			continue
+		}
+		if err := listDir(ctx, root, dir, in, ignore, &files, &truncated); err != nil {
+			return "", err
+		}
+		if truncated {
+			break
+		}
+	}
+	if in.Format == "tree" {
+		return formatListTree(displayPath(dir), files, truncated), nil
+	}
+	if truncated {
+		files = append(files, "... truncated")
+	}
+	if in.Format == "flat" {
+		return strings.Join(files, "\n"), nil
+	}
+
+	// HTML escaping would turn the "->" of resolved links into "-\u003e".
+	var result strings.Builder
+	encoder := json.NewEncoder(&result)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(files); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(result.String(), "\n"), nil
+}
+
+// listDir appends the entries below dir that in selects to files, as paths relative to base,
+// and sets truncated once in.Limit is reached
+func listDir(ctx context.Context, dir, base string, in ListFilesInput, ignore *ignoreRules, files *[]string, truncated *bool) error {
+	return walk(dir, func(pathStr string, info os.FileInfo, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		if err != nil {
 			return err
 		}
@@ -78,21 +334,161 @@ func ListFiles(input json.RawMessage) (string, error) {
 		if err != nil {
 			return err
 		}
+		if relPath == "." {
+			return nil
+		}
+		if ignore.matches(pathStr) {
+			return skipEntry(info.IsDir())
+		}
 
-		if relPath != "." {
-			if info.IsDir() {
-				files = append(files, relPath+"/")
-			} else {
-				files = append(files, relPath)
+		listed := true
+		switch in.Type {
+		case "files":
+			listed = !info.IsDir()
+		case "dirs":
+			listed = info.IsDir()
+		}
+		if listed {
+			if in.Limit > 0 && len(*files) >= in.Limit {
+				*truncated = true
+				return filepath.SkipAll
+			}
+			name, err := filepath.Rel(base, pathStr)
+			if err != nil {
+				return err
+			}
+			switch {
+			case info.IsDir():
+				*files = append(*files, name+"/")
+			case info.Mode()&os.ModeSymlink != 0:
+				// Walk doesn't follow symlinks, so linked directories are never descended into and can't loop.
+				entry := name + "@"
+				if in.ResolveLinks {
+					if target, err := FS.Readlink(pathStr); err == nil {
+						entry += " -> " + target
+					}
+				}
+				*files = append(*files, entry)
+			default:
+				*files = append(*files, name)
 			}
 		}
+
+		if in.MaxDepth > 0 && info.IsDir() && strings.Count(relPath, string(filepath.Separator))+1 >= in.MaxDepth {
+			return filepath.SkipDir
+		}
 		return nil
 	})
+}
+
+// listNode is an entry of a list_files tree, keyed by its file name
+type listNode struct {
+	key      string
+	label    string
+	children []*listNode
+}
+
+// formatListTree arranges entries as listed by list_files, in walk order, as a tree below root.
+// Directories that weren't listed themselves, e.g. when only files are, are still shown to hold their entries.
+func formatListTree(root string, entries []string, truncated bool) string {
+	top := &listNode{}
+	for _, entry := range entries {
+		name, target, isLink := strings.Cut(entry, " -> ")
+		segments := strings.Split(strings.TrimSuffix(name, "/"), string(filepath.Separator))
+		node := top
+		for i, segment := range segments {
+			var child *listNode
+			for _, c := range node.children {
+				if c.key == segment {
+					child = c
+					break
+				}
+			}
+			if child == nil {
+				child = &listNode{key: segment, label: segment + "/"}
+				if i == len(segments)-1 && !strings.HasSuffix(name, "/") {
+					child.label = segment
+					if isLink {
+						child.label += " -> " + target
+					}
+				}
+				node.children = append(node.children, child)
+			}
+			node = child
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(root + "/\n")
+	writeListTree(&sb, top.children, "")
+	if truncated {
+		sb.WriteString("... truncated\n")
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// writeListTree writes nodes below prefix with the connectors of directory_tree
+func writeListTree(sb *strings.Builder, nodes []*listNode, prefix string) {
+	for i, node := range nodes {
+		connector, indent := "├── ", "│   "
+		if i == len(nodes)-1 {
+			connector, indent = "└── ", "    "
+		}
+		sb.WriteString(prefix + connector + node.label + "\n")
+		writeListTree(sb, node.children, prefix+indent)
+	}
+}
+
+// FileInfoDefinition allows inspecting file metadata
+var FileInfoDefinition = agent.ToolDefinition{
+	Name:        "get_file_info",
+	Description: "Get metadata for a file or directory: size in bytes, permissions, whether it is a directory, and last modification time. Use this to check what a path is or how large a file is before reading it.",
+	InputSchema: GenerateSchema[FileInfoInput](),
+	Function:    FileInfo,
+	ReadOnly:    true,
+}
+
+// FileInfoInput holds input for get_file_info tool
+type FileInfoInput struct {
+	Path string `json:"path" jsonschema_description:"The relative path of the file or directory."`
+}
+
+// FileInfoResult holds the metadata returned by get_file_info
+type FileInfoResult struct {
+	Size    int64     `json:"size"`
+	Mode    string    `json:"mode"`
+	IsDir   bool      `json:"is_dir"`
+	ModTime time.Time `json:"modtime"`
+}
+
+// FileInfo returns metadata about a file or directory
+func FileInfo(ctx context.Context, input json.RawMessage) (string, error) {
+	var in FileInfoInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", err
+	}
+	if in.Path == "" {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "path must not be empty")
+	}
+
+	p, err := resolvePath(in.Path)
+	if err != nil {
+		return "", err
+	}
+	info, err := FS.Stat(p)
 	if err != nil {
+		if os.IsNotExist(err) {
+			return "", agent.NewToolError(agent.ToolErrorNotFound, "%s does not exist", in.Path)
+		}
 		return "", err
 	}
 
-	result, err := json.Marshal(files)
+	result, err := json.Marshal(FileInfoResult{
+		Size:    info.Size(),
+		Mode:    info.Mode().String(),
+		IsDir:   info.IsDir(),
+		ModTime: info.ModTime(),
+	})
 	if err != nil {
 		return "", err
 	}
@@ -101,15 +497,17 @@ func ListFiles(input json.RawMessage) (string, error) {
 
 // EditFileDefinition allows editing file contents
 var EditFileDefinition = agent.ToolDefinition{
-	Name:        "edit_file",
+	Name: "edit_file",
 	Description: `Make edits to a text file.
 
-Replaces 'old_str' with 'new_str' in the given file. 'old_str' and 'new_str' MUST be different from each other.
+Replaces 'old_str' with 'new_str' in the given file. 'old_str' and 'new_str' MUST be different from each other, and 'old_str' MUST match exactly once in the file.
 
 If the file specified with path doesn't exist, it will be created.
 `,
-	InputSchema: GenerateSchema[EditFileInput](),
-	Function:    EditFile,
+	InputSchema:          GenerateSchema[EditFileInput](),
+	Function:             EditFile,
+	RequiresConfirmation: true,
+	ChangedPaths:         changedInputPaths("path"),
 }
 
 // EditFileInput holds input for edit_file tool
@@ -123,47 +521,628 @@ type EditFileInput struct {
 var EditFileInputSchema = GenerateSchema[EditFileInput]()
 
 // EditFile edits or creates a file
-func EditFile(input json.RawMessage) (string, error) {
+func EditFile(ctx context.Context, input json.RawMessage) (string, error) {
 	var in EditFileInput
 	if err := json.Unmarshal(input, &in); err != nil {
 		return "", err
 	}
 
 	if in.Path == "" || in.OldStr == in.NewStr {
-		return "", fmt.Errorf("invalid input parameters")
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "invalid input parameters")
+	}
+
+	p, err := resolvePath(in.Path)
+	if err != nil {
+		return "", err
 	}
+	defer lockPaths(p)()
 
-	content, err := os.ReadFile(in.Path)
+	content, err := FS.ReadFile(p)
 	if err != nil {
 		if os.IsNotExist(err) && in.OldStr == "" {
-			return createNewFile(in.Path, in.NewStr)
+			if DryRun {
+				return dryRunf("Would create file %s:\n%s", in.Path, unifiedDiff(in.Path, "", in.NewStr)), nil
+			}
+			if err := recordUndo(p); err != nil {
+				return "", err
+			}
+			if err := createNewFile(p, in.NewStr); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Successfully created file %s", in.Path), nil
 		}
 		return "", err
 	}
 
+	if in.OldStr == "" {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "file already exists, old_str must not be empty when editing it")
+	}
+
+	oldContent := string(content)
+	switch count := strings.Count(oldContent, in.OldStr); {
+	case count == 0:
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "old_str not found in file")
+	case count > 1:
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "old_str matches %d times in file, include more surrounding context so it matches exactly once", count)
+	}
+	newContent := strings.Replace(oldContent, in.OldStr, in.NewStr, 1)
+
+	if DryRun {
+		return dryRunf("Would edit file %s:\n%s", in.Path, unifiedDiff(in.Path, oldContent, newContent)), nil
+	}
+
+	backup, err := backupFile(p)
+	if err != nil {
+		return "", err
+	}
+	if err := recordUndo(p); err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(p, []byte(newContent)); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Successfully edited file %s%s:\n%s", in.Path, backupNote(backup), unifiedDiff(in.Path, oldContent, newContent)), nil
+}
+
+// WriteFileDefinition allows replacing the entire content of a file
+var WriteFileDefinition = agent.ToolDefinition{
+	Name:                 "write_file",
+	Description:          "Write content to a file, replacing everything in it. Creates the file and its parent directories if they don't exist. Use this for new files or full rewrites; use edit_file for small changes to existing files.",
+	InputSchema:          GenerateSchema[WriteFileInput](),
+	Function:             WriteFile,
+	RequiresConfirmation: true,
+	ChangedPaths:         changedInputPaths("path"),
+}
+
+// WriteFileInput holds input for write_file tool
+type WriteFileInput struct {
+	Path    string `json:"path" jsonschema_description:"The relative path of the file to write."`
+	Content string `json:"content" jsonschema_description:"The complete new content of the file."`
+}
+
+// WriteFile writes content to a file, truncating it if it exists
+func WriteFile(ctx context.Context, input json.RawMessage) (string, error) {
+	var in WriteFileInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", err
+	}
+	if in.Path == "" {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "path must not be empty")
+	}
+
+	p, err := resolvePath(in.Path)
+	if err != nil {
+		return "", err
+	}
+	defer lockPaths(p)()
+	oldContent, err := FS.ReadFile(p)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	if DryRun {
+		return dryRunf("Would write %d bytes to file %s:\n%s", len(in.Content), in.Path, unifiedDiff(in.Path, string(oldContent), in.Content)), nil
+	}
+	backup, err := backupFile(p)
+	if err != nil {
+		return "", err
+	}
+	if err := recordUndo(p); err != nil {
+		return "", err
+	}
+	if err := createNewFile(p, in.Content); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Successfully wrote %d bytes to file %s%s", len(in.Content), in.Path, backupNote(backup)), nil
+}
+
+// ReplaceLinesDefinition allows replacing a range of lines in a file
+var ReplaceLinesDefinition = agent.ToolDefinition{
+	Name:                 "replace_lines",
+	Description:          "Replace the lines from start_line to end_line (1-based, inclusive) in a file with new_content. Use this instead of edit_file when exact string matching is unreliable, e.g. because of whitespace. An empty new_content deletes the lines.",
+	InputSchema:          GenerateSchema[ReplaceLinesInput](),
+	Function:             ReplaceLines,
+	RequiresConfirmation: true,
+	ChangedPaths:         changedInputPaths("path"),
+}
+
+// ReplaceLinesInput holds input for replace_lines tool
+type ReplaceLinesInput struct {
+	Path       string `json:"path" jsonschema_description:"The relative path of the file to edit."`
+	StartLine  int    `json:"start_line" jsonschema:"minimum=1" jsonschema_description:"The first line to replace, starting at 1."`
+	EndLine    int    `json:"end_line" jsonschema:"minimum=1" jsonschema_description:"The last line to replace, inclusive."`
+	NewContent string `json:"new_content" jsonschema_description:"The text that replaces the given lines."`
+}
+
+// ReplaceLines replaces a range of lines in a file
+func ReplaceLines(ctx context.Context, input json.RawMessage) (string, error) {
+	var in ReplaceLinesInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", err
+	}
+	if in.Path == "" {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "path must not be empty")
+	}
+
+	p, err := resolvePath(in.Path)
+	if err != nil {
+		return "", err
+	}
+	defer lockPaths(p)()
+	content, err := FS.ReadFile(p)
+	if err != nil {
+		return "", err
+	}
+
 	oldContent := string(content)
-	newContent := strings.Replace(oldContent, in.OldStr, in.NewStr, -1)
+	lines := splitLines(oldContent)
+	if in.StartLine < 1 || in.EndLine < in.StartLine || in.EndLine > len(lines) {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "invalid line range %d-%d: file has %d lines", in.StartLine, in.EndLine, len(lines))
+	}
+
+	newLines := append([]string{}, lines[:in.StartLine-1]...)
+	newLines = append(newLines, splitLines(in.NewContent)...)
+	newLines = append(newLines, lines[in.EndLine:]...)
+	newContent := strings.Join(newLines, "\n")
+	if strings.HasSuffix(oldContent, "\n") && len(newLines) > 0 {
+		newContent += "\n"
+	}
+
+	if DryRun {
+		return dryRunf("Would edit file %s:\n%s", in.Path, unifiedDiff(in.Path, oldContent, newContent)), nil
+	}
+	backup, err := backupFile(p)
+	if err != nil {
+		return "", err
+	}
+	if err := recordUndo(p); err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(p, []byte(newContent)); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Successfully edited file %s%s:\n%s", in.Path, backupNote(backup), unifiedDiff(in.Path, oldContent, newContent)), nil
+}
+
+// AppendFileDefinition allows appending to files
+var AppendFileDefinition = agent.ToolDefinition{
+	Name:                 "append_to_file",
+	Description:          "Append content to the end of a file, creating the file and its parent directories if they don't exist. Returns the new size of the file.",
+	InputSchema:          GenerateSchema[AppendFileInput](),
+	Function:             AppendFile,
+	RequiresConfirmation: true,
+	ChangedPaths:         changedInputPaths("path"),
+}
+
+// AppendFileInput holds input for append_to_file tool
+type AppendFileInput struct {
+	Path    string `json:"path" jsonschema_description:"The relative path of the file to append to."`
+	Content string `json:"content" jsonschema_description:"The text to append to the file."`
+}
+
+// AppendFile appends content to a file, creating it if necessary
+func AppendFile(ctx context.Context, input json.RawMessage) (string, error) {
+	var in AppendFileInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", err
+	}
+	if in.Path == "" {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "path must not be empty")
+	}
+
+	p, err := resolvePath(in.Path)
+	if err != nil {
+		return "", err
+	}
+	defer lockPaths(p)()
+	if DryRun {
+		return dryRunf("Would append %d bytes to file %s", len(in.Content), in.Path), nil
+	}
+	if err := FS.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	if err := recordUndo(p); err != nil {
+		return "", err
+	}
+	f, err := FS.OpenFile(p, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.WriteString(f, in.Content); err != nil {
+		f.Close()
+		return "", fmt.Errorf("failed to append to file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to append to file: %w", err)
+	}
+
+	info, err := FS.Stat(p)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Successfully appended %d bytes to file %s (new size: %d bytes)", len(in.Content), in.Path, info.Size()), nil
+}
+
+// fileMode returns the permissions of the file at p, or 0644 if it doesn't exist yet
+func fileMode(p string) os.FileMode {
+	info, err := FS.Stat(p)
+	if err != nil {
+		return 0644
+	}
+	return info.Mode().Perm()
+}
+
+// createNewFile writes content to a file, creating it and its parent directories as needed
+func createNewFile(filePath, content string) error {
+	if err := FS.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := writeFileAtomic(filePath, []byte(content)); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic replaces the content of the file at p by writing a temporary file next to it
+// and renaming it over p, so an interrupted write never leaves p truncated. The mode of an
+// existing file is kept.
+func writeFileAtomic(p string, data []byte) error {
+	// Write through symlinks rather than replacing the link with a regular file.
+	if target, err := FS.EvalSymlinks(p); err == nil {
+		if err := checkRealPath(p, displayPath(p)); err != nil {
+			return err
+		}
+		p = target
+	}
+	mode := fileMode(p)
+
+	tmp, err := FS.CreateTemp(filepath.Dir(p), "."+filepath.Base(p)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer FS.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	// CreateTemp always uses 0600, so apply the final mode explicitly.
+	if err := FS.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return FS.Rename(tmp.Name(), p)
+}
+
+// MoveFileDefinition allows moving individual files
+var MoveFileDefinition = agent.ToolDefinition{
+	Name:                 "move_file",
+	Description:          "Move a file from the source path to the destination path, creating parent directories of the destination as needed. Works across filesystems. Fails if the destination exists unless overwrite is true.",
+	InputSchema:          GenerateSchema[MoveFileInput](),
+	Function:             MoveFile,
+	RequiresConfirmation: true,
+	ChangedPaths:         changedInputPaths("source", "dest"),
+}
+
+// MoveFileInput holds input for move_file tool
+type MoveFileInput struct {
+	Source    string `json:"source" jsonschema_description:"The current relative path of the file."`
+	Dest      string `json:"dest" jsonschema_description:"The new relative path for the file."`
+	Overwrite bool   `json:"overwrite,omitempty" jsonschema_description:"Whether to replace an existing file at dest. Defaults to false."`
+}
+
+// MoveFile moves a file, falling back to copy and delete across filesystems
+func MoveFile(ctx context.Context, input json.RawMessage) (string, error) {
+	var in MoveFileInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", err
+	}
+	if in.Source == "" || in.Dest == "" {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "source and dest must not be empty")
+	}
+
+	source, err := resolvePath(in.Source)
+	if err != nil {
+		return "", err
+	}
+	dest, err := resolvePath(in.Dest)
+	if err != nil {
+		return "", err
+	}
+	defer lockPaths(source, dest)()
+
+	info, err := FS.Stat(source)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "source is a directory, use rename_directory instead")
+	}
+	if destInfo, err := FS.Stat(dest); err == nil && source != dest {
+		if destInfo.IsDir() {
+			return "", agent.NewToolError(agent.ToolErrorInvalidInput, "dest %s is a directory, give the new path of the file instead", in.Dest)
+		}
+		if !in.Overwrite {
+			return "", agent.NewToolError(agent.ToolErrorAlreadyExists, "%s already exists, set overwrite to replace it", in.Dest)
+		}
+	}
+	if DryRun {
+		return dryRunf("Would move file from %s to %s", in.Source, in.Dest), nil
+	}
+	if err := FS.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := recordUndo(source, dest); err != nil {
+		return "", err
+	}
+
+	err = FS.Rename(source, dest)
+	var linkErr *os.LinkError
+	if errors.As(err, &linkErr) && errors.Is(linkErr.Err, syscall.EXDEV) {
+		if err := copyFileReplacing(source, dest, info); err != nil {
+			return "", err
+		}
+		err = FS.Remove(source)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to move file: %w", err)
+	}
+	return fmt.Sprintf("Successfully moved file from %s to %s", in.Source, in.Dest), nil
+}
+
+// CopyFileDefinition allows copying individual files
+var CopyFileDefinition = agent.ToolDefinition{
+	Name:                 "copy_file",
+	Description:          "Copy a file from the source path to the destination path, keeping its permissions and creating parent directories of the destination as needed. Fails if the destination exists unless overwrite is true.",
+	InputSchema:          GenerateSchema[CopyFileInput](),
+	Function:             CopyFile,
+	RequiresConfirmation: true,
+	ChangedPaths:         changedInputPaths("dest"),
+}
+
+// CopyFileInput holds input for copy_file tool
+type CopyFileInput struct {
+	Source    string `json:"source" jsonschema_description:"The relative path of the file to copy."`
+	Dest      string `json:"dest" jsonschema_description:"The relative path of the copy."`
+	Overwrite bool   `json:"overwrite,omitempty" jsonschema_description:"Whether to replace an existing file at dest. Defaults to false."`
+}
+
+// CopyFile copies a file, preserving its permissions and modification time
+func CopyFile(ctx context.Context, input json.RawMessage) (string, error) {
+	var in CopyFileInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", err
+	}
+	if in.Source == "" || in.Dest == "" {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "source and dest must not be empty")
+	}
 
-	if oldContent == newContent && in.OldStr != "" {
-		return "", fmt.Errorf("old_str not found in file")
+	source, err := resolvePath(in.Source)
+	if err != nil {
+		return "", err
+	}
+	dest, err := resolvePath(in.Dest)
+	if err != nil {
+		return "", err
+	}
+	if source == dest {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "source and dest are the same file")
+	}
+	defer lockPaths(source, dest)()
+
+	info, err := FS.Stat(source)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "source is a directory, only files can be copied")
+	}
+	if destInfo, err := FS.Stat(dest); err == nil {
+		if destInfo.IsDir() {
+			return "", agent.NewToolError(agent.ToolErrorInvalidInput, "dest %s is a directory, give the path of the copy instead", in.Dest)
+		}
+		if !in.Overwrite {
+			return "", agent.NewToolError(agent.ToolErrorAlreadyExists, "%s already exists, set overwrite to replace it", in.Dest)
+		}
+	}
+	if DryRun {
+		return dryRunf("Would copy %d bytes from %s to %s", info.Size(), in.Source, in.Dest), nil
+	}
+	if err := FS.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := recordUndo(dest); err != nil {
+		return "", err
+	}
+	if err := copyFile(source, dest, info); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Successfully copied %d bytes from %s to %s", info.Size(), in.Source, in.Dest), nil
+}
+
+// ChmodDefinition allows changing file permissions
+var ChmodDefinition = agent.ToolDefinition{
+	Name:                 "chmod",
+	Description:          "Change the permissions of a file or directory, e.g. to make a script executable with mode \"0755\".",
+	InputSchema:          GenerateSchema[ChmodInput](),
+	Function:             Chmod,
+	RequiresConfirmation: true,
+	ChangedPaths:         changedInputPaths("path"),
+}
+
+// ChmodInput holds input for chmod tool
+type ChmodInput struct {
+	Path string `json:"path" jsonschema_description:"The relative path of the file or directory."`
+	Mode string `json:"mode" jsonschema:"pattern=^[0-7]{3}[0-7]?$" jsonschema_description:"The new permissions as an octal string, e.g. \"0755\" or \"644\"."`
+}
+
+// Chmod changes the permissions of a file or directory
+func Chmod(ctx context.Context, input json.RawMessage) (string, error) {
+	var in ChmodInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", err
+	}
+	if in.Path == "" {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "path must not be empty")
+	}
+	mode, err := strconv.ParseUint(in.Mode, 8, 32)
+	if err != nil || mode > 0o7777 {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "invalid mode %q: must be an octal permission string like \"0755\"", in.Mode)
+	}
+
+	p, err := resolvePath(in.Path)
+	if err != nil {
+		return "", err
+	}
+	info, err := FS.Stat(p)
+	if err != nil {
+		return "", err
+	}
+	// The setuid, setgid, and sticky bits are stored apart from the permission bits in os.FileMode.
+	newMode := os.FileMode(mode & 0o777)
+	if mode&0o4000 != 0 {
+		newMode |= os.ModeSetuid
+	}
+	if mode&0o2000 != 0 {
+		newMode |= os.ModeSetgid
+	}
+	if mode&0o1000 != 0 {
+		newMode |= os.ModeSticky
 	}
 
-	if err := os.WriteFile(in.Path, []byte(newContent), 0644); err != nil {
+	oldMode := fmt.Sprintf("%04o", info.Mode().Perm())
+	if DryRun {
+		return dryRunf("Would change mode of %s from %s to %04o", in.Path, oldMode, mode), nil
+	}
+	defer lockPaths(p)()
+	if err := recordUndo(p); err != nil {
 		return "", err
 	}
-	return "OK", nil
+	if err := FS.Chmod(p, newMode); err != nil {
+		return "", fmt.Errorf("failed to change mode: %w", err)
+	}
+	return fmt.Sprintf("Changed mode of %s from %s to %04o", in.Path, oldMode, mode), nil
+}
+
+// AllowExternalSymlinks lets the symlink tool create links whose target is outside RootDir and the
+// WorkspaceRoots. Such links let other tools reach files outside them through them.
+var AllowExternalSymlinks bool
+
+// SymlinkDefinition allows creating symbolic links
+var SymlinkDefinition = agent.ToolDefinition{
+	Name:                 "symlink",
+	Description:          "Create a symbolic link at link_path pointing to target, like ln -s. A relative target is interpreted relative to the directory containing the link. Fails if link_path already exists.",
+	InputSchema:          GenerateSchema[SymlinkInput](),
+	Function:             Symlink,
+	RequiresConfirmation: true,
+	ChangedPaths:         changedInputPaths("link_path"),
+}
+
+// SymlinkInput holds input for symlink tool
+type SymlinkInput struct {
+	Target   string `json:"target" jsonschema_description:"The path the link points to, stored as given. Relative targets are relative to the link's directory."`
+	LinkPath string `json:"link_path" jsonschema_description:"The relative path of the link to create."`
 }
 
-// createNewFile creates a new file with content
-func createNewFile(filePath, content string) (string, error) {
-	dir := path.Dir(filePath)
-	if dir != "." {
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return "", fmt.Errorf("failed to create directory: %w", err)
+// Symlink creates a symbolic link, keeping its target inside the roots unless AllowExternalSymlinks is set
+func Symlink(ctx context.Context, input json.RawMessage) (string, error) {
+	var in SymlinkInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", err
+	}
+	if in.Target == "" || in.LinkPath == "" {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "target and link_path must not be empty")
+	}
+	link, err := resolvePath(in.LinkPath)
+	if err != nil {
+		return "", err
+	}
+
+	if !AllowExternalSymlinks {
+		// The target is checked where it really leads, as links that already exist, in the link's
+		// directory or in the target, could lead it out of the roots.
+		target := in.Target
+		if !filepath.IsAbs(target) {
+			dir, err := realPath(filepath.Dir(link), 0)
+			if err != nil {
+				return "", err
+			}
+			target = filepath.Join(dir, target)
+		}
+		if err := checkRealPath(filepath.Clean(target), "symlink target "+in.Target); err != nil {
+			return "", err
 		}
 	}
-	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
-		return "", fmt.Errorf("failed to create file: %w", err)
+
+	if info, err := FS.Lstat(link); err == nil {
+		if info.Mode()&os.ModeSymlink != 0 {
+			existing, _ := FS.Readlink(link)
+			return "", agent.NewToolError(agent.ToolErrorAlreadyExists, "%s already exists and is a symlink to %s", in.LinkPath, existing)
+		}
+		return "", agent.NewToolError(agent.ToolErrorAlreadyExists, "%s already exists", in.LinkPath)
+	}
+	if DryRun {
+		return dryRunf("Would create symlink %s -> %s", in.LinkPath, in.Target), nil
+	}
+	defer lockPaths(link)()
+	if err := recordUndo(append(missingDirs(filepath.Dir(link)), link)...); err != nil {
+		return "", err
+	}
+	if err := FS.MkdirAll(filepath.Dir(link), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := FS.Symlink(in.Target, link); err != nil {
+		return "", fmt.Errorf("failed to create symlink: %w", err)
+	}
+	return fmt.Sprintf("Created symlink %s -> %s", in.LinkPath, in.Target), nil
+}
+
+// copyFileReplacing copies src to a temporary file next to dst and renames it into place, so that
+// a failed copy leaves neither a partial file nor a changed dst behind
+func copyFileReplacing(src, dst string, info os.FileInfo) error {
+	tmp, err := FS.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer FS.Remove(tmp.Name())
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := copyFile(src, tmp.Name(), info); err != nil {
+		return err
+	}
+	return FS.Rename(tmp.Name(), dst)
+}
+
+// copyFile copies src to dst, preserving the permissions and modtime in info
+func copyFile(src, dst string, info os.FileInfo) error {
+	in, err := FS.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := FS.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+
+	// OpenFile's mode is subject to umask and ignored for existing files.
+	if err := FS.Chmod(dst, info.Mode().Perm()); err != nil {
+		return err
 	}
-	return fmt.Sprintf("Successfully created file %s", filePath), nil
+	return FS.Chtimes(dst, info.ModTime(), info.ModTime())
 }
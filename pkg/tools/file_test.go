@@ -0,0 +1,318 @@
+package tools
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestSymlink(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		link    string
+		escapes bool
+	}{
+		{name: "file inside", target: "a.txt", link: "to-a"},
+		{name: "directory inside", target: "sub", link: "to-sub"},
+		{name: "relative to link directory", target: "../a.txt", link: "sub/to-a"},
+		{name: "parent of root", target: "..", link: "up", escapes: true},
+		{name: "out of link directory", target: "../..", link: "sub/up", escapes: true},
+		{name: "absolute outside", target: "/etc", link: "etc", escapes: true},
+		{name: "through existing link to outside", target: "out/x", link: "to-out", escapes: true},
+		{name: "in directory reached through link", target: "..", link: "self/up", escapes: true},
+	}
+
+	useTempDir(t)
+	writeFiles(t, map[string]string{"a.txt": "a", "sub/b.txt": "b"})
+	symlink(t, t.TempDir(), "out")
+	symlink(t, ".", "self")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := callTool(t, Symlink, SymlinkInput{Target: tt.target, LinkPath: tt.link})
+			if tt.escapes && !errors.Is(err, ErrPathEscape) {
+				t.Errorf("symlink %s -> %s = %v, want ErrPathEscape", tt.link, tt.target, err)
+			}
+			if !tt.escapes && err != nil {
+				t.Errorf("symlink %s -> %s = %v, want no error", tt.link, tt.target, err)
+			}
+		})
+	}
+}
+
+func TestSymlinkChainDoesNotEscape(t *testing.T) {
+	root := useTempDir(t)
+	secret := filepath.Join(filepath.Dir(root), "oen-chained-link-secret.txt")
+	if err := os.WriteFile(secret, []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Remove(secret) })
+
+	if _, err := callTool(t, Symlink, SymlinkInput{Target: ".", LinkPath: "d"}); err != nil {
+		if errors.Is(err, errors.ErrUnsupported) {
+			t.Skip("symlinks are not supported")
+		}
+		t.Fatalf("symlink d -> . = %v", err)
+	}
+	// d/e is created in the root itself, so .. is the directory above it.
+	if _, err := callTool(t, Symlink, SymlinkInput{Target: "..", LinkPath: "d/e"}); !errors.Is(err, ErrPathEscape) {
+		t.Fatalf("symlink d/e -> .. = %v, want ErrPathEscape", err)
+	}
+	name := filepath.Join("d", "e", filepath.Base(secret))
+	if _, err := callTool(t, ReadFile, ReadFileInput{Path: name}); err == nil {
+		t.Errorf("read_file %s succeeded, want an error", name)
+	}
+	// Without the link, this creates e in the root rather than writing above it.
+	if _, err := callTool(t, WriteFile, WriteFileInput{Path: name, Content: "changed"}); err != nil {
+		t.Errorf("write_file %s = %v", name, err)
+	}
+	content, err := os.ReadFile(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "secret" {
+		t.Errorf("file above the root = %q, want it unchanged", content)
+	}
+}
+
+func TestEditFileKeepsMode(t *testing.T) {
+	tests := []struct {
+		name string
+		mode os.FileMode
+	}{
+		{name: "executable", mode: 0755},
+		{name: "private", mode: 0600},
+		{name: "regular", mode: 0644},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := useMemFS(t)
+			writeFiles(t, map[string]string{"run.sh": "#!/bin/sh\necho old\n"})
+			p := filepath.Join(root, "run.sh")
+			if err := FS.Chmod(p, tt.mode); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := callTool(t, EditFile, EditFileInput{Path: "run.sh", OldStr: "old", NewStr: "new"}); err != nil {
+				t.Fatal(err)
+			}
+			if got := readFile(t, "run.sh"); got != "#!/bin/sh\necho new\n" {
+				t.Errorf("content = %q", got)
+			}
+			info, err := FS.Stat(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != tt.mode {
+				t.Errorf("mode = %v, want %v", info.Mode().Perm(), tt.mode)
+			}
+		})
+	}
+}
+
+func TestWriteFileCreatesRegularMode(t *testing.T) {
+	useMemFS(t)
+	if _, err := callTool(t, WriteFile, WriteFileInput{Path: "new/file.txt", Content: "new"}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := FS.Stat(filepath.Join(RootDir, "new", "file.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("mode = %v, want 0644", info.Mode().Perm())
+	}
+}
+
+func TestFileTools(t *testing.T) {
+	tests := []struct {
+		name    string
+		step    toolStep
+		output  string
+		want    map[string]string
+		gone    []string
+		wantErr bool
+	}{
+		{
+			name:   "read file",
+			step:   toolStep{ReadFile, ReadFileInput{Path: "a.txt"}},
+			output: "one\ntwo\nthree\n",
+		},
+		{
+			name:   "read file lines",
+			step:   toolStep{ReadFileLines, ReadFileLinesInput{Path: "a.txt", StartLine: 2, LineCount: 1}},
+			output: "two",
+		},
+		{
+			name:    "read missing file",
+			step:    toolStep{ReadFile, ReadFileInput{Path: "missing.txt"}},
+			wantErr: true,
+		},
+		{
+			name: "write new file",
+			step: toolStep{WriteFile, WriteFileInput{Path: "new/b.txt", Content: "b"}},
+			want: map[string]string{"new/b.txt": "b", "a.txt": "one\ntwo\nthree\n"},
+		},
+		{
+			name: "overwrite file",
+			step: toolStep{WriteFile, WriteFileInput{Path: "a.txt", Content: "replaced"}},
+			want: map[string]string{"a.txt": "replaced"},
+		},
+		{
+			name: "edit file",
+			step: toolStep{EditFile, EditFileInput{Path: "a.txt", OldStr: "two", NewStr: "2"}},
+			want: map[string]string{"a.txt": "one\n2\nthree\n"},
+		},
+		{
+			name:    "edit with ambiguous old_str",
+			step:    toolStep{EditFile, EditFileInput{Path: "dup.txt", OldStr: "x", NewStr: "y"}},
+			want:    map[string]string{"dup.txt": "x x"},
+			wantErr: true,
+		},
+		{
+			name: "replace lines",
+			step: toolStep{ReplaceLines, ReplaceLinesInput{Path: "a.txt", StartLine: 1, EndLine: 2, NewContent: "first"}},
+			want: map[string]string{"a.txt": "first\nthree\n"},
+		},
+		{
+			name: "append to file",
+			step: toolStep{AppendFile, AppendFileInput{Path: "a.txt", Content: "four\n"}},
+			want: map[string]string{"a.txt": "one\ntwo\nthree\nfour\n"},
+		},
+		{
+			name: "append creates file",
+			step: toolStep{AppendFile, AppendFileInput{Path: "c.txt", Content: "c"}},
+			want: map[string]string{"c.txt": "c"},
+		},
+		{
+			name: "move file",
+			step: toolStep{MoveFile, MoveFileInput{Source: "a.txt", Dest: "moved/a.txt"}},
+			want: map[string]string{"moved/a.txt": "one\ntwo\nthree\n"},
+			gone: []string{"a.txt"},
+		},
+		{
+			name:    "move over existing file",
+			step:    toolStep{MoveFile, MoveFileInput{Source: "a.txt", Dest: "dup.txt"}},
+			want:    map[string]string{"a.txt": "one\ntwo\nthree\n", "dup.txt": "x x"},
+			wantErr: true,
+		},
+		{
+			name: "move over existing file with overwrite",
+			step: toolStep{MoveFile, MoveFileInput{Source: "a.txt", Dest: "dup.txt", Overwrite: true}},
+			want: map[string]string{"dup.txt": "one\ntwo\nthree\n"},
+			gone: []string{"a.txt"},
+		},
+		{
+			name: "copy file",
+			step: toolStep{CopyFile, CopyFileInput{Source: "a.txt", Dest: "copy.txt"}},
+			want: map[string]string{"a.txt": "one\ntwo\nthree\n", "copy.txt": "one\ntwo\nthree\n"},
+		},
+		{
+			name:    "copy over existing file",
+			step:    toolStep{CopyFile, CopyFileInput{Source: "a.txt", Dest: "dup.txt"}},
+			want:    map[string]string{"dup.txt": "x x"},
+			wantErr: true,
+		},
+		{
+			name: "copy over existing file with overwrite",
+			step: toolStep{CopyFile, CopyFileInput{Source: "a.txt", Dest: "dup.txt", Overwrite: true}},
+			want: map[string]string{"dup.txt": "one\ntwo\nthree\n"},
+		},
+		{
+			name:    "path outside the root",
+			step:    toolStep{WriteFile, WriteFileInput{Path: "../escape.txt", Content: "x"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMemFS(t)
+			oldStack := undoStack
+			t.Cleanup(func() { undoStack = oldStack })
+			writeFiles(t, map[string]string{"a.txt": "one\ntwo\nthree\n", "dup.txt": "x x"})
+
+			output, err := callTool(t, tt.step.function, tt.step.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !strings.Contains(output, tt.output) {
+				t.Errorf("output = %q, want it to contain %q", output, tt.output)
+			}
+			for name, want := range tt.want {
+				if got := readFile(t, name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+			for _, name := range tt.gone {
+				if _, err := FS.Stat(filepath.Join(RootDir, name)); !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("%s still exists (%v)", name, err)
+				}
+			}
+			if _, err := FS.Stat(filepath.Join(filepath.Dir(RootDir), "escape.txt")); err == nil {
+				t.Error("wrote outside the root")
+			}
+		})
+	}
+}
+
+// crossDeviceFS fails renames of from as if they crossed filesystems, and writes to files if failCopy is set
+type crossDeviceFS struct {
+	FileSystem
+	from     string
+	failCopy bool
+}
+
+func (f crossDeviceFS) Rename(oldpath, newpath string) error {
+	if oldpath == f.from {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EXDEV}
+	}
+	return f.FileSystem.Rename(oldpath, newpath)
+}
+
+func (f crossDeviceFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	if f.failCopy && flag&os.O_WRONLY != 0 {
+		return nil, errors.New("no space left on device")
+	}
+	return f.FileSystem.OpenFile(name, flag, perm)
+}
+
+func TestMoveFileAcrossFilesystems(t *testing.T) {
+	tests := []struct {
+		name     string
+		failCopy bool
+		want     map[string]string
+	}{
+		{name: "copy", want: map[string]string{"b.txt": "a"}},
+		{name: "failed copy", failCopy: true, want: map[string]string{"a.txt": "a", "b.txt": "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMemFS(t)
+			oldStack := undoStack
+			t.Cleanup(func() { undoStack = oldStack })
+			writeFiles(t, map[string]string{"a.txt": "a", "b.txt": "b"})
+			FS = crossDeviceFS{FileSystem: FS, from: filepath.Join(RootDir, "a.txt"), failCopy: tt.failCopy}
+
+			_, err := callTool(t, MoveFile, MoveFileInput{Source: "a.txt", Dest: "b.txt", Overwrite: true})
+			if (err != nil) != tt.failCopy {
+				t.Fatalf("error = %v, want an error %v", err, tt.failCopy)
+			}
+			if got := tree(t); len(got) != len(tt.want)+1 {
+				t.Errorf("files = %v, want only %v", got, tt.want)
+			}
+			for name, want := range tt.want {
+				if got := readFile(t, name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,538 @@
+package tools
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FS is the file system the tools read and write. It defaults to the real one and can be
+// replaced, e.g. by a MemFS to run tools without touching the disk. Tools that run programs,
+// such as git_diff, go_test, and run_command, always work on the real disk.
+var FS FileSystem = OSFS{}
+
+// FileSystem is the file access the tools need, the read side of fs.FS plus writing. Like the os
+// functions of the same names, its methods take OS paths; the tools always pass absolute ones.
+type FileSystem interface {
+	Open(name string) (File, error)
+	OpenFile(name string, flag int, perm fs.FileMode) (File, error)
+	CreateTemp(dir, pattern string) (File, error)
+	Stat(name string) (fs.FileInfo, error)
+	Lstat(name string) (fs.FileInfo, error)
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	ReadDir(name string) ([]fs.DirEntry, error)
+	MkdirAll(path string, perm fs.FileMode) error
+	Remove(name string) error
+	RemoveAll(path string) error
+	Rename(oldpath, newpath string) error
+	Chmod(name string, mode fs.FileMode) error
+	Chtimes(name string, atime, mtime time.Time) error
+	Symlink(oldname, newname string) error
+	Readlink(name string) (string, error)
+	EvalSymlinks(path string) (string, error)
+}
+
+// File is an open file of a FileSystem
+type File interface {
+	fs.File
+	io.ReaderAt
+	io.Writer
+	Name() string
+	Sync() error
+}
+
+// OSFS is the FileSystem of the operating system
+type OSFS struct{}
+
+func (OSFS) Open(name string) (File, error) { return os.Open(name) }
+
+func (OSFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	return os.OpenFile(name, flag, perm)
+}
+
+func (OSFS) CreateTemp(dir, pattern string) (File, error) { return os.CreateTemp(dir, pattern) }
+func (OSFS) Stat(name string) (fs.FileInfo, error)        { return os.Stat(name) }
+func (OSFS) Lstat(name string) (fs.FileInfo, error)       { return os.Lstat(name) }
+func (OSFS) ReadFile(name string) ([]byte, error)         { return os.ReadFile(name) }
+func (OSFS) ReadDir(name string) ([]fs.DirEntry, error)   { return os.ReadDir(name) }
+func (OSFS) MkdirAll(path string, perm fs.FileMode) error { return os.MkdirAll(path, perm) }
+func (OSFS) Remove(name string) error                     { return os.Remove(name) }
+func (OSFS) RemoveAll(path string) error                  { return os.RemoveAll(path) }
+func (OSFS) Rename(oldpath, newpath string) error         { return os.Rename(oldpath, newpath) }
+func (OSFS) Chmod(name string, mode fs.FileMode) error    { return os.Chmod(name, mode) }
+func (OSFS) Symlink(oldname, newname string) error        { return os.Symlink(oldname, newname) }
+func (OSFS) Readlink(name string) (string, error)         { return os.Readlink(name) }
+func (OSFS) EvalSymlinks(path string) (string, error)     { return filepath.EvalSymlinks(path) }
+func (OSFS) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+func (OSFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+
+// walk is filepath.Walk on FS
+func walk(root string, fn filepath.WalkFunc) error {
+	return walkDir(root, func(p string, d fs.DirEntry, err error) error {
+		var info fs.FileInfo
+		if d != nil {
+			var infoErr error
+			if info, infoErr = d.Info(); err == nil && infoErr != nil {
+				err = infoErr
+			}
+		}
+		return fn(p, info, err)
+	})
+}
+
+// walkDir is filepath.WalkDir on FS
+func walkDir(root string, fn fs.WalkDirFunc) error {
+	info, err := FS.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkDirEntry(root, fs.FileInfoToDirEntry(info), fn)
+	}
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+// walkDirEntry walks the entry d at p and, if it is a directory, everything below it
+func walkDirEntry(p string, d fs.DirEntry, fn fs.WalkDirFunc) error {
+	if err := fn(p, d, nil); err != nil || !d.IsDir() {
+		if err == filepath.SkipDir && d.IsDir() {
+			err = nil
+		}
+		return err
+	}
+	entries, err := FS.ReadDir(p)
+	if err != nil {
+		// Report the error and let fn decide whether to go on without the entries.
+		if err = fn(p, d, err); err != nil {
+			if err == filepath.SkipDir {
+				err = nil
+			}
+			return err
+		}
+	}
+	for _, entry := range entries {
+		if err := walkDirEntry(filepath.Join(p, entry.Name()), entry, fn); err != nil {
+			if err == filepath.SkipDir {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// MemFS is a FileSystem held in memory, for running tools without touching the disk. It has no
+// symlinks. Relative paths are made absolute against the process's working directory.
+type MemFS struct {
+	mu    sync.Mutex
+	nodes map[string]*memNode
+	temps int
+}
+
+// memNode is a file or, if its mode has fs.ModeDir set, a directory of a MemFS
+type memNode struct {
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// NewMemFS returns a MemFS that only has the root directory. Create RootDir in it with MkdirAll
+// before running tools.
+func NewMemFS() *MemFS {
+	return &MemFS{nodes: map[string]*memNode{
+		string(filepath.Separator): {mode: fs.ModeDir | 0755, modTime: time.Now()},
+	}}
+}
+
+// memPath returns the clean absolute form of name, which keys the nodes of a MemFS
+func memPath(name string) string {
+	p, err := filepath.Abs(name)
+	if err != nil {
+		return filepath.Clean(name)
+	}
+	return p
+}
+
+// node returns the node at the clean absolute path p or a PathError for op.
+// It must be called with m.mu held.
+func (m *MemFS) node(op, p string) (*memNode, error) {
+	n, ok := m.nodes[p]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: p, Err: fs.ErrNotExist}
+	}
+	return n, nil
+}
+
+// parentDir checks that the parent of p is a directory. It must be called with m.mu held.
+func (m *MemFS) parentDir(op, p string) error {
+	parent, err := m.node(op, filepath.Dir(p))
+	if err != nil {
+		return &fs.PathError{Op: op, Path: p, Err: fs.ErrNotExist}
+	}
+	if !parent.mode.IsDir() {
+		return &fs.PathError{Op: op, Path: p, Err: errNotDir}
+	}
+	return nil
+}
+
+// errNotDir and errIsDir are the errors MemFS gives in place of ENOTDIR and EISDIR
+var (
+	errNotDir = errors.New("not a directory")
+	errIsDir  = errors.New("is a directory")
+)
+
+// children returns the names of the nodes directly inside the directory p, sorted.
+// It must be called with m.mu held.
+func (m *MemFS) children(p string) []string {
+	prefix := p
+	if !strings.HasSuffix(prefix, string(filepath.Separator)) {
+		prefix += string(filepath.Separator)
+	}
+	var names []string
+	for key := range m.nodes {
+		if rest, ok := strings.CutPrefix(key, prefix); ok && rest != "" && !strings.ContainsRune(rest, filepath.Separator) {
+			names = append(names, rest)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (m *MemFS) Open(name string) (File, error) {
+	return m.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (m *MemFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	p := memPath(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, ok := m.nodes[p]
+	switch {
+	case ok && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, &fs.PathError{Op: "open", Path: p, Err: fs.ErrExist}
+	case ok && n.mode.IsDir() && flag&(os.O_WRONLY|os.O_RDWR) != 0:
+		return nil, &fs.PathError{Op: "open", Path: p, Err: errIsDir}
+	case !ok && flag&os.O_CREATE == 0:
+		return nil, &fs.PathError{Op: "open", Path: p, Err: fs.ErrNotExist}
+	case !ok:
+		if err := m.parentDir("open", p); err != nil {
+			return nil, err
+		}
+		n = &memNode{mode: perm.Perm(), modTime: time.Now()}
+		m.nodes[p] = n
+	}
+	if flag&os.O_TRUNC != 0 && flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		n.data = nil
+		n.modTime = time.Now()
+	}
+	return &memFile{fs: m, name: p, node: n, flag: flag}, nil
+}
+
+func (m *MemFS) CreateTemp(dir, pattern string) (File, error) {
+	prefix, suffix, _ := strings.Cut(pattern, "*")
+	m.mu.Lock()
+	m.temps++
+	name := filepath.Join(dir, fmt.Sprintf("%s%d%s", prefix, m.temps, suffix))
+	m.mu.Unlock()
+	return m.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+}
+
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	p := memPath(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, err := m.node("stat", p)
+	if err != nil {
+		return nil, err
+	}
+	return n.info(p), nil
+}
+
+func (m *MemFS) Lstat(name string) (fs.FileInfo, error) {
+	return m.Stat(name)
+}
+
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	p := memPath(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, err := m.node("open", p)
+	if err != nil {
+		return nil, err
+	}
+	if n.mode.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: p, Err: errIsDir}
+	}
+	return bytes.Clone(n.data), nil
+}
+
+func (m *MemFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	f, err := m.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return errors.Join(err, f.Close())
+}
+
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	p := memPath(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, err := m.node("open", p)
+	if err != nil {
+		return nil, err
+	}
+	if !n.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdirent", Path: p, Err: errNotDir}
+	}
+	var entries []fs.DirEntry
+	for _, child := range m.children(p) {
+		childPath := filepath.Join(p, child)
+		entries = append(entries, fs.FileInfoToDirEntry(m.nodes[childPath].info(childPath)))
+	}
+	return entries, nil
+}
+
+func (m *MemFS) MkdirAll(path string, perm fs.FileMode) error {
+	p := memPath(path)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var missing []string
+	for dir := p; ; dir = filepath.Dir(dir) {
+		if n, ok := m.nodes[dir]; ok {
+			if !n.mode.IsDir() {
+				return &fs.PathError{Op: "mkdir", Path: dir, Err: errNotDir}
+			}
+			break
+		}
+		missing = append(missing, dir)
+	}
+	for _, dir := range missing {
+		m.nodes[dir] = &memNode{mode: fs.ModeDir | perm.Perm(), modTime: time.Now()}
+	}
+	return nil
+}
+
+func (m *MemFS) Remove(name string) error {
+	p := memPath(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, err := m.node("remove", p)
+	if err != nil {
+		return err
+	}
+	if n.mode.IsDir() && len(m.children(p)) > 0 {
+		return &fs.PathError{Op: "remove", Path: p, Err: errors.New("directory not empty")}
+	}
+	delete(m.nodes, p)
+	return nil
+}
+
+func (m *MemFS) RemoveAll(path string) error {
+	p := memPath(path)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key := range m.nodes {
+		if key == p || strings.HasPrefix(key, p+string(filepath.Separator)) {
+			delete(m.nodes, key)
+		}
+	}
+	return nil
+}
+
+func (m *MemFS) Rename(oldpath, newpath string) error {
+	src, dst := memPath(oldpath), memPath(newpath)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	linkError := func(err error) error {
+		return &os.LinkError{Op: "rename", Old: src, New: dst, Err: err}
+	}
+	n, ok := m.nodes[src]
+	if !ok {
+		return linkError(fs.ErrNotExist)
+	}
+	if src == dst {
+		return nil
+	}
+	if n.mode.IsDir() && strings.HasPrefix(dst, src+string(filepath.Separator)) {
+		return linkError(fs.ErrInvalid)
+	}
+	if err := m.parentDir("rename", dst); err != nil {
+		return linkError(err)
+	}
+	if existing, ok := m.nodes[dst]; ok {
+		switch {
+		case existing.mode.IsDir() && !n.mode.IsDir():
+			return linkError(errIsDir)
+		case !existing.mode.IsDir() && n.mode.IsDir():
+			return linkError(errNotDir)
+		case existing.mode.IsDir() && len(m.children(dst)) > 0:
+			return linkError(errors.New("directory not empty"))
+		}
+	}
+	for key, node := range m.nodes {
+		if key == src || strings.HasPrefix(key, src+string(filepath.Separator)) {
+			delete(m.nodes, key)
+			m.nodes[dst+strings.TrimPrefix(key, src)] = node
+		}
+	}
+	return nil
+}
+
+func (m *MemFS) Chmod(name string, mode fs.FileMode) error {
+	p := memPath(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, err := m.node("chmod", p)
+	if err != nil {
+		return err
+	}
+	n.mode = n.mode.Type() | mode.Perm()
+	return nil
+}
+
+func (m *MemFS) Chtimes(name string, _, mtime time.Time) error {
+	p := memPath(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, err := m.node("chtimes", p)
+	if err != nil {
+		return err
+	}
+	n.modTime = mtime
+	return nil
+}
+
+func (m *MemFS) Symlink(_, newname string) error {
+	return &os.LinkError{Op: "symlink", New: memPath(newname), Err: errors.ErrUnsupported}
+}
+
+func (m *MemFS) Readlink(name string) (string, error) {
+	if _, err := m.Lstat(name); err != nil {
+		return "", err
+	}
+	return "", &fs.PathError{Op: "readlink", Path: memPath(name), Err: fs.ErrInvalid}
+}
+
+func (m *MemFS) EvalSymlinks(path string) (string, error) {
+	if _, err := m.Lstat(path); err != nil {
+		return "", err
+	}
+	return memPath(path), nil
+}
+
+// info returns the fs.FileInfo of the node at p
+func (n *memNode) info(p string) fs.FileInfo {
+	return memInfo{name: filepath.Base(p), size: int64(len(n.data)), mode: n.mode, modTime: n.modTime}
+}
+
+// memInfo is the fs.FileInfo of a MemFS node
+type memInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return i.size }
+func (i memInfo) Mode() fs.FileMode  { return i.mode }
+func (i memInfo) ModTime() time.Time { return i.modTime }
+func (i memInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i memInfo) Sys() any           { return nil }
+
+// memFile is an open file of a MemFS. Writes go straight to its node.
+type memFile struct {
+	fs     *MemFS
+	name   string
+	node   *memNode
+	flag   int
+	offset int64
+	closed bool
+}
+
+func (f *memFile) Name() string { return f.name }
+func (f *memFile) Sync() error  { return nil }
+
+func (f *memFile) Stat() (fs.FileInfo, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	return f.node.info(f.name), nil
+}
+
+func (f *memFile) Read(b []byte) (int, error) {
+	n, err := f.ReadAt(b, f.offset)
+	f.offset += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (f *memFile) ReadAt(b []byte, off int64) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	switch {
+	case f.closed:
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrClosed}
+	case f.flag&os.O_WRONLY != 0:
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrPermission}
+	case f.node.mode.IsDir():
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: errIsDir}
+	case off >= int64(len(f.node.data)):
+		return 0, io.EOF
+	}
+	n := copy(b, f.node.data[off:])
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *memFile) Write(b []byte) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	switch {
+	case f.closed:
+		return 0, &fs.PathError{Op: "write", Path: f.name, Err: fs.ErrClosed}
+	case f.flag&(os.O_WRONLY|os.O_RDWR) == 0:
+		return 0, &fs.PathError{Op: "write", Path: f.name, Err: fs.ErrPermission}
+	}
+	if f.flag&os.O_APPEND != 0 {
+		f.offset = int64(len(f.node.data))
+	}
+	if end := f.offset + int64(len(b)); end > int64(len(f.node.data)) {
+		f.node.data = append(f.node.data, make([]byte, end-int64(len(f.node.data)))...)
+	}
+	copy(f.node.data[f.offset:], b)
+	f.offset += int64(len(b))
+	f.node.modTime = time.Now()
+	return len(b), nil
+}
+
+func (f *memFile) Close() error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+	return nil
+}
@@ -0,0 +1,203 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/MarkusZoppelt/oen/pkg/agent"
+)
+
+func init() {
+	agent.DefaultRegistry.Register(GitDiffDefinition)
+	agent.DefaultRegistry.Register(GitStatusDefinition)
+}
+
+// GitDiffDefinition allows inspecting uncommitted changes
+var GitDiffDefinition = agent.ToolDefinition{
+	Name:        "git_diff",
+	Description: "Show the uncommitted changes in the git repository of the working directory, both unstaged and staged, as unified diffs. Use this to review what has been modified so far.",
+	InputSchema: GenerateSchema[GitDiffInput](),
+	Function:    GitDiff,
+	ReadOnly:    true,
+}
+
+// GitDiffInput holds input for git_diff tool
+type GitDiffInput struct {
+	Path string `json:"path,omitempty" jsonschema_description:"Optional relative path of a file or directory to limit the diff to. Defaults to the whole working directory."`
+}
+
+// GitDiff returns the unstaged and staged changes below the given path
+func GitDiff(ctx context.Context, input json.RawMessage) (string, error) {
+	var in GitDiffInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", err
+	}
+	p, err := resolvePath(in.Path)
+	if err != nil {
+		return "", err
+	}
+
+	// git diff falls back to comparing plain files outside a repository, so check first.
+	if _, err := gitPrefix(ctx); err != nil {
+		return "", err
+	}
+	unstaged, err := runGit(ctx, "diff", "--", p)
+	if err != nil {
+		return "", err
+	}
+	staged, err := runGit(ctx, "diff", "--staged", "--", p)
+	if err != nil {
+		return "", err
+	}
+	if unstaged == "" && staged == "" {
+		return "No uncommitted changes", nil
+	}
+
+	var sb strings.Builder
+	if unstaged != "" {
+		sb.WriteString("Unstaged changes:\n" + unstaged)
+	}
+	if staged != "" {
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString("Staged changes:\n" + staged)
+	}
+	return sb.String(), nil
+}
+
+// GitStatusDefinition allows listing changed files
+var GitStatusDefinition = agent.ToolDefinition{
+	Name:        "git_status",
+	Description: "List the files in the working directory that are modified, added, deleted, renamed, untracked, or conflicted according to git. Use this to find out what has changed before looking at git_diff.",
+	InputSchema: GenerateSchema[GitStatusInput](),
+	Function:    GitStatus,
+	ReadOnly:    true,
+}
+
+// GitStatusInput holds input for git_status tool
+type GitStatusInput struct{}
+
+// GitStatusEntry is a single changed file reported by git_status
+type GitStatusEntry struct {
+	Path string `json:"path"`
+	// OrigPath is the previous path of a renamed or copied file
+	OrigPath string `json:"orig_path,omitempty"`
+	Status   string `json:"status"`
+	// Staged reports whether the change is in the index
+	Staged bool `json:"staged"`
+}
+
+// GitStatus returns the changed files below the working directory as JSON
+func GitStatus(ctx context.Context, input json.RawMessage) (string, error) {
+	prefix, err := gitPrefix(ctx)
+	if err != nil {
+		return "", err
+	}
+	out, err := runGit(ctx, "status", "--porcelain=v1", "-z", "--", ".")
+	if err != nil {
+		return "", err
+	}
+
+	entries := []GitStatusEntry{}
+	// Each record is "XY path", followed by the original path for renames and copies.
+	records := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+	for i := 0; i < len(records); i++ {
+		record := records[i]
+		if len(record) < 4 {
+			continue
+		}
+		x, y := record[0], record[1]
+		entry := GitStatusEntry{
+			Path:   strings.TrimPrefix(record[3:], prefix),
+			Status: gitStatusName(x, y),
+			Staged: x != ' ' && x != '?' && x != '!',
+		}
+		if (x == 'R' || x == 'C') && i+1 < len(records) {
+			i++
+			entry.OrigPath = strings.TrimPrefix(records[i], prefix)
+		}
+		entries = append(entries, entry)
+	}
+
+	result, err := json.Marshal(entries)
+	if err != nil {
+		return "", err
+	}
+	return string(result), nil
+}
+
+// gitStatusName describes the porcelain status code XY, preferring the index status over the worktree one
+func gitStatusName(x, y byte) string {
+	if x == '?' {
+		return "untracked"
+	}
+	if x == '!' {
+		return "ignored"
+	}
+	if x == 'U' || y == 'U' || (x == 'A' && y == 'A') || (x == 'D' && y == 'D') {
+		return "conflicted"
+	}
+	code := x
+	if code == ' ' {
+		code = y
+	}
+	switch code {
+	case 'M':
+		return "modified"
+	case 'T':
+		return "type changed"
+	case 'A':
+		return "added"
+	case 'D':
+		return "deleted"
+	case 'R':
+		return "renamed"
+	case 'C':
+		return "copied"
+	default:
+		return string([]byte{x, y})
+	}
+}
+
+// runGit runs git with args in the working directory and returns its output, turning common failures into clear errors
+func runGit(ctx context.Context, args ...string) (string, error) {
+	root, err := resolvePath(".")
+	if err != nil {
+		return "", err
+	}
+	// git status would otherwise refresh the index, which fails when read-only tools run concurrently.
+	cmd := exec.CommandContext(ctx, "git", append([]string{"--no-optional-locks"}, args...)...)
+	cmd.Dir = root
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if errors.Is(err, exec.ErrNotFound) {
+			return "", fmt.Errorf("git is not installed")
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if strings.Contains(strings.ToLower(msg), "not a git repository") {
+			return "", fmt.Errorf("the working directory is not inside a git repository")
+		}
+		return "", fmt.Errorf("git %s failed: %w: %s", args[0], err, msg)
+	}
+	return stdout.String(), nil
+}
+
+// gitPrefix returns the path of the working directory relative to the top of its git repository, e.g. "pkg/" or ""
+func gitPrefix(ctx context.Context) (string, error) {
+	prefix, err := runGit(ctx, "rev-parse", "--show-prefix")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(prefix), nil
+}
@@ -0,0 +1,205 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/MarkusZoppelt/oen/pkg/agent"
+)
+
+// defaultGoTestTimeout is used when no timeout is given for go_test
+const defaultGoTestTimeout = 5 * time.Minute
+
+func init() {
+	agent.DefaultRegistry.Register(GoTestDefinition)
+}
+
+// GoTestDefinition allows running the tests of a Go project
+var GoTestDefinition = agent.ToolDefinition{
+	Name:                 "go_test",
+	Description:          "Run go test in the working directory and return whether the tests passed, with the output of every failing test and any build errors. Use this to check your changes to a Go project.",
+	InputSchema:          GenerateSchema[GoTestInput](),
+	Function:             GoTest,
+	RequiresConfirmation: true,
+	Category:             agent.CategoryCommand,
+	// Tests enforce their own timeout, which may exceed the default tool timeout.
+	Timeout: maxCommandTimeout + 5*time.Second,
+}
+
+// GoTestInput holds input for go_test tool
+type GoTestInput struct {
+	Package        string `json:"package,omitempty" jsonschema_description:"Optional package pattern to test, e.g. ./pkg/tools or ./pkg/.... Defaults to ./... for all packages."`
+	Run            string `json:"run,omitempty" jsonschema_description:"Optional regular expression selecting the tests to run, as for go test -run."`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty" jsonschema:"minimum=0,maximum=600" jsonschema_description:"Optional timeout in seconds, at most 600. Defaults to 300."`
+}
+
+// testEvent is a line of go test -json output, see go doc test2json
+type testEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Output  string
+	// ImportPath is set instead of Package on build-output events
+	ImportPath string
+}
+
+// GoTest runs go test and summarizes the result
+func GoTest(ctx context.Context, input json.RawMessage) (string, error) {
+	var in GoTestInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", err
+	}
+	pkg := in.Package
+	if pkg == "" {
+		pkg = "./..."
+	}
+	if strings.HasPrefix(pkg, "-") {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "package must be a package path or pattern, not a flag")
+	}
+	args := []string{"test", "-json"}
+	if in.Run != "" {
+		args = append(args, "-run", in.Run)
+	}
+	args = append(args, pkg)
+
+	if DryRun {
+		return dryRunf("Would run go %s", strings.Join(args, " ")), nil
+	}
+
+	timeout := defaultGoTestTimeout
+	if in.TimeoutSeconds > 0 {
+		timeout = min(time.Duration(in.TimeoutSeconds)*time.Second, maxCommandTimeout)
+	}
+	result, err := runProgram(ctx, timeout, "go", args...)
+	if err != nil {
+		return "", err
+	}
+	return summarizeGoTest(result), nil
+}
+
+// programResult is the outcome of a finished program
+type programResult struct {
+	stdout, stderr string
+	exitCode       int
+}
+
+// runProgram runs name in the working directory, killing it once timeout has passed or ctx is done.
+// A non-zero exit code is reported in the result rather than as an error.
+func runProgram(ctx context.Context, timeout time.Duration, name string, args ...string) (programResult, error) {
+	if _, err := exec.LookPath(name); err != nil {
+		return programResult{}, agent.NewToolError(agent.ToolErrorNotFound, "%s is not installed or not on PATH", name)
+	}
+	dir, err := WorkDir()
+	if err != nil {
+		return programResult{}, err
+	}
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(runCtx, name, args...)
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	setProcessGroup(cmd)
+
+	err = cmd.Run()
+	if ctx.Err() != nil {
+		return programResult{}, ctx.Err()
+	}
+	if runCtx.Err() == context.DeadlineExceeded {
+		return programResult{}, agent.NewToolError(agent.ToolErrorTimeout, "%s timed out after %s; output so far:\n%s%s", name, timeout, stdout.String(), stderr.String())
+	}
+	result := programResult{stdout: stdout.String(), stderr: stderr.String()}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return programResult{}, fmt.Errorf("failed to run %s: %w", name, err)
+		}
+		result.exitCode = exitErr.ExitCode()
+	}
+	return result, nil
+}
+
+// summarizeGoTest turns go test -json output into a short report: the overall result and package
+// counts, then the output of every failed test, of failed packages without a failed test, and build errors
+func summarizeGoTest(result programResult) string {
+	var packages []string
+	packageResults := map[string]string{}
+	outputs := map[string]*strings.Builder{}
+	var failedTests []testEvent
+	var buildOutput strings.Builder
+
+	scanner := bufio.NewScanner(strings.NewReader(result.stdout))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var event testEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			// Lines that aren't events, e.g. from a test writing to stdout directly
+			buildOutput.WriteString(scanner.Text() + "\n")
+			continue
+		}
+		key := event.Package + " " + event.Test
+		switch event.Action {
+		case "output":
+			if outputs[key] == nil {
+				outputs[key] = &strings.Builder{}
+			}
+			outputs[key].WriteString(event.Output)
+		case "build-output":
+			buildOutput.WriteString(event.Output)
+		case "pass", "fail", "skip":
+			if event.Test != "" {
+				if event.Action == "fail" {
+					failedTests = append(failedTests, event)
+				}
+				continue
+			}
+			if _, seen := packageResults[event.Package]; !seen {
+				packages = append(packages, event.Package)
+			}
+			packageResults[event.Package] = event.Action
+		}
+	}
+
+	counts := map[string]int{}
+	for _, pkg := range packages {
+		counts[packageResults[pkg]]++
+	}
+	var sb strings.Builder
+	if result.exitCode == 0 {
+		fmt.Fprintf(&sb, "PASS: %d packages passed, %d without tests\n", counts["pass"], counts["skip"])
+		return sb.String()
+	}
+	fmt.Fprintf(&sb, "FAIL: %d of %d packages failed, %d tests failed\n", counts["fail"], len(packages), len(failedTests))
+
+	failedPackages := map[string]bool{}
+	for _, test := range failedTests {
+		failedPackages[test.Package] = true
+		fmt.Fprintf(&sb, "\n--- %s (%s)\n", test.Test, test.Package)
+		if out := outputs[test.Package+" "+test.Test]; out != nil {
+			sb.WriteString(out.String())
+		}
+	}
+	// Packages can fail without a failed test, e.g. when they don't compile or a test panics
+	for _, pkg := range packages {
+		if packageResults[pkg] != "fail" || failedPackages[pkg] {
+			continue
+		}
+		fmt.Fprintf(&sb, "\n--- package %s\n", pkg)
+		if out := outputs[pkg+" "]; out != nil {
+			sb.WriteString(out.String())
+		}
+	}
+	if buildOutput.Len() > 0 || result.stderr != "" {
+		sb.WriteString("\nBuild output:\n" + buildOutput.String() + result.stderr)
+	}
+	return sb.String()
+}
@@ -0,0 +1,74 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/MarkusZoppelt/oen/pkg/agent"
+)
+
+// defaultVetTimeout bounds a go_vet run
+const defaultVetTimeout = 5 * time.Minute
+
+// VetCommand is the program and leading arguments go_vet runs, followed by the package pattern.
+// It can be pointed at another linter, e.g. golangci-lint run.
+var VetCommand = []string{"go", "vet"}
+
+func init() {
+	agent.DefaultRegistry.Register(GoVetDefinition)
+}
+
+// GoVetDefinition allows checking a Go project for suspicious code
+var GoVetDefinition = agent.ToolDefinition{
+	Name:        "go_vet",
+	Description: "Run go vet, or the linter the user configured instead, in the working directory and return its diagnostics. Use this after changing a Go project to find and fix problems you introduced.",
+	InputSchema: GenerateSchema[GoVetInput](),
+	Function:    GoVet,
+	Category:    agent.CategoryCommand,
+	Timeout:     defaultVetTimeout + 5*time.Second,
+}
+
+// GoVetInput holds input for go_vet tool
+type GoVetInput struct {
+	Package string `json:"package,omitempty" jsonschema_description:"Optional package pattern to check, e.g. ./pkg/tools or ./pkg/.... Defaults to ./... for all packages."`
+}
+
+// GoVet runs VetCommand on a package pattern and returns its diagnostics
+func GoVet(ctx context.Context, input json.RawMessage) (string, error) {
+	var in GoVetInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", err
+	}
+	pkg := in.Package
+	if pkg == "" {
+		pkg = "./..."
+	}
+	if strings.HasPrefix(pkg, "-") {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "package must be a package path or pattern, not a flag")
+	}
+	if len(VetCommand) == 0 {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "no vet command is configured")
+	}
+	args := append(append([]string(nil), VetCommand[1:]...), pkg)
+	command := strings.Join(append([]string{VetCommand[0]}, args...), " ")
+
+	if DryRun {
+		return dryRunf("Would run %s", command), nil
+	}
+
+	result, err := runProgram(ctx, defaultVetTimeout, VetCommand[0], args...)
+	if err != nil {
+		return "", err
+	}
+	// go vet reports on stderr and golangci-lint on stdout.
+	output := strings.TrimSpace(result.stdout + result.stderr)
+	if result.exitCode == 0 && output == "" {
+		return command + " found no issues", nil
+	}
+	if result.exitCode == 0 {
+		return command + " passed:\n" + output, nil
+	}
+	return command + " reported issues:\n" + output, nil
+}
@@ -0,0 +1,179 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/MarkusZoppelt/oen/pkg/agent"
+)
+
+func init() {
+	agent.DefaultRegistry.Register(HTTPGetDefinition)
+}
+
+// httpTimeout bounds a whole http_get request, including redirects and reading the body
+const httpTimeout = 30 * time.Second
+
+// maxDownloadBytes is the largest response body http_get accepts
+const maxDownloadBytes = 10 << 20
+
+// maxHTTPBodyChars is how much of a response body http_get returns when it isn't saved to a file
+const maxHTTPBodyChars = 50000
+
+// ErrForbiddenAddress is returned when http_get would connect to a local, private, or metadata address
+var ErrForbiddenAddress = errors.New("connecting to local, private, or link-local addresses is not allowed")
+
+// HTTPGetDefinition allows fetching web pages and files
+var HTTPGetDefinition = agent.ToolDefinition{
+	Name:                 "http_get",
+	Description:          "Fetch a URL over http or https and return the response body, or save it to a file if save_path is given. Use this to read specs, documentation, or gists. Bodies larger than 10 MB are rejected and long text responses are truncated.",
+	InputSchema:          GenerateSchema[HTTPGetInput](),
+	Function:             HTTPGet,
+	RequiresConfirmation: true,
+	Category:             agent.CategoryNetwork,
+	Timeout:              httpTimeout + 5*time.Second,
+	ChangedPaths:         changedInputPaths("save_path"),
+}
+
+// HTTPGetInput holds input for http_get tool
+type HTTPGetInput struct {
+	URL      string `json:"url" jsonschema_description:"The http or https URL to fetch."`
+	SavePath string `json:"save_path,omitempty" jsonschema_description:"Optional relative path to save the response body to instead of returning it."`
+}
+
+// httpClient refuses to connect to non-public addresses, which is checked after DNS resolution
+// and for every redirect so that neither can be used to reach internal services.
+var httpClient = &http.Client{
+	Timeout: httpTimeout,
+	Transport: &http.Transport{
+		// A proxy would make the connection, bypassing the address check.
+		Proxy: nil,
+		DialContext: (&net.Dialer{
+			Timeout: 10 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				return checkPublicAddress(address)
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return fmt.Errorf("stopped after 10 redirects")
+		}
+		return checkScheme(req.URL)
+	},
+}
+
+// HTTPGet fetches a URL and returns or saves its body
+func HTTPGet(ctx context.Context, input json.RawMessage) (string, error) {
+	var in HTTPGetInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", err
+	}
+	u, err := url.Parse(in.URL)
+	if err != nil {
+		return "", fmt.Errorf("invalid url: %w", err)
+	}
+	if err := checkScheme(u); err != nil {
+		return "", err
+	}
+
+	var savePath string
+	if in.SavePath != "" {
+		if savePath, err = resolvePath(in.SavePath); err != nil {
+			return "", err
+		}
+		if DryRun {
+			return dryRunf("Would download %s to %s", in.URL, in.SavePath), nil
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("request failed with status %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDownloadBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+	if len(body) > maxDownloadBytes {
+		return "", fmt.Errorf("response is larger than %d bytes", maxDownloadBytes)
+	}
+
+	if savePath != "" {
+		// Saving is a write like write_file's, so it takes the same lock, backup, and undo steps.
+		defer lockPaths(savePath)()
+		backup, err := backupFile(savePath)
+		if err != nil {
+			return "", err
+		}
+		if err := recordUndo(savePath); err != nil {
+			return "", err
+		}
+		if err := createNewFile(savePath, string(body)); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Saved %d bytes from %s to %s%s", len(body), in.URL, in.SavePath, backupNote(backup)), nil
+	}
+
+	if looksBinary(body) {
+		return "", fmt.Errorf("response is binary (%s, %d bytes); pass save_path to save it to a file", resp.Header.Get("Content-Type"), len(body))
+	}
+	text := string(body)
+	if len(text) > maxHTTPBodyChars {
+		cut := maxHTTPBodyChars
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		text = fmt.Sprintf("%s\n... truncated, showing %d of %d bytes; pass save_path to save the full response", text[:cut], cut, len(body))
+	}
+	return text, nil
+}
+
+// checkScheme rejects URLs that aren't http or https
+func checkScheme(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return agent.NewToolError(agent.ToolErrorInvalidInput, "unsupported url scheme %q, only http and https are allowed", u.Scheme)
+	}
+	return nil
+}
+
+// checkPublicAddress rejects a resolved host:port whose IP is loopback, private, link-local
+// (which includes cloud metadata endpoints), multicast, or unspecified
+func checkPublicAddress(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, ip)
+	}
+	return nil
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, which netip doesn't treat as private
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
@@ -0,0 +1,89 @@
+package tools
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestHTTPGetSave(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+		backup   bool
+	}{
+		{name: "new file"},
+		{name: "existing file", existing: "old"},
+		{name: "existing file with backup", existing: "old", backup: true},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("downloaded"))
+	}))
+	defer server.Close()
+	// The real client refuses loopback addresses, so talk to the test server directly.
+	oldClient := httpClient
+	httpClient = server.Client()
+	t.Cleanup(func() { httpClient = oldClient })
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMemFS(t)
+			oldBackup, oldStack := Backup, undoStack
+			Backup, undoStack = tt.backup, nil
+			t.Cleanup(func() { Backup, undoStack = oldBackup, oldStack })
+			if tt.existing != "" {
+				writeFiles(t, map[string]string{"dl/file.txt": tt.existing})
+			}
+
+			if _, err := callTool(t, HTTPGet, HTTPGetInput{URL: server.URL, SavePath: "dl/file.txt"}); err != nil {
+				t.Fatal(err)
+			}
+			if got := readFile(t, "dl/file.txt"); got != "downloaded" {
+				t.Errorf("saved file = %q, want %q", got, "downloaded")
+			}
+			if tt.backup {
+				if got := readFile(t, "dl/file.txt.bak"); got != tt.existing {
+					t.Errorf("backup = %q, want %q", got, tt.existing)
+				}
+			}
+
+			if _, err := callTool(t, Undo, UndoInput{}); err != nil {
+				t.Fatalf("undo = %v", err)
+			}
+			if tt.existing == "" {
+				if _, err := FS.Stat(filepath.Join(RootDir, "dl", "file.txt")); err == nil {
+					t.Error("undo kept the downloaded file, want it removed")
+				}
+			} else if got := readFile(t, "dl/file.txt"); got != tt.existing {
+				t.Errorf("after undo = %q, want %q", got, tt.existing)
+			}
+		})
+	}
+}
+
+func TestHTTPGetTruncatesOnRuneBoundary(t *testing.T) {
+	// The odd first byte puts every two-byte rune across the truncation limit.
+	body := "a" + strings.Repeat("é", maxHTTPBodyChars)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+	oldClient := httpClient
+	httpClient = server.Client()
+	t.Cleanup(func() { httpClient = oldClient })
+
+	out, err := callTool(t, HTTPGet, HTTPGetInput{URL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "truncated") {
+		t.Fatalf("output of %d bytes is not truncated", len(out))
+	}
+	if !utf8.ValidString(out) {
+		t.Error("truncated output is not valid UTF-8")
+	}
+}
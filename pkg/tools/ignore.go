@@ -0,0 +1,238 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MarkusZoppelt/oen/pkg/agent"
+)
+
+// IgnoreFileName is the file in RootDir, or in a workspace root, listing paths below it that tools must
+// not touch, one glob per line
+const IgnoreFileName = ".oenignore"
+
+// ConfigFileName is the name of the config file in RootDir, the config package's FileName, which is
+// protected even if oeN was started with another config file
+const ConfigFileName = "oen.yaml"
+
+// ConfigFile is the absolute path of the config file oeN was started with, if any. Like the ignore
+// and workspace files, tools can't read or change it, so that the model can't lift its own limits.
+var ConfigFile string
+
+// ignoreRules holds the ignore files of every root, RootDir first. The zero value ignores nothing.
+type ignoreRules struct {
+	roots []*rootIgnore
+	// protected holds the absolute paths of the files that are always ignored, as given and with
+	// symlinks resolved
+	protected []string
+}
+
+// rootIgnore holds the patterns of the ignore file in root
+type rootIgnore struct {
+	root string
+	// realRoot is root with symlinks resolved, to match paths that were resolved as well
+	realRoot string
+	patterns [][]string
+}
+
+// ignoreCacheEntry holds the rules of an ignore file until it changes
+type ignoreCacheEntry struct {
+	modTime time.Time
+	size    int64
+	rules   *rootIgnore
+}
+
+var (
+	ignoreMu sync.Mutex
+	// ignoreCache holds the parsed ignore files by path
+	ignoreCache = map[string]ignoreCacheEntry{}
+)
+
+// loadIgnoreRules returns the rules of the ignore files in RootDir and the WorkspaceRoots,
+// reading each again only after it changed
+func loadIgnoreRules() (*ignoreRules, error) {
+	all, err := roots()
+	if err != nil {
+		return nil, err
+	}
+	rules := &ignoreRules{}
+	for _, root := range all {
+		r, err := loadRootIgnore(root)
+		if err != nil {
+			return nil, err
+		}
+		rules.roots = append(rules.roots, r)
+	}
+	protected := []string{filepath.Join(all[0], WorkspaceFileName), filepath.Join(all[0], ConfigFileName)}
+	if ConfigFile != "" {
+		protected = append(protected, ConfigFile)
+	}
+	for _, p := range protected {
+		rules.protected = append(rules.protected, p)
+		if real, err := FS.EvalSymlinks(p); err == nil && real != p {
+			rules.protected = append(rules.protected, real)
+		}
+	}
+	return rules, nil
+}
+
+// loadRootIgnore returns the rules of the ignore file in root
+func loadRootIgnore(root string) (*rootIgnore, error) {
+	realRoot, err := FS.EvalSymlinks(root)
+	if err != nil {
+		realRoot = root
+	}
+	file := filepath.Join(root, IgnoreFileName)
+	info, err := FS.Stat(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return &rootIgnore{root: root, realRoot: realRoot}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", displayPath(file), err)
+	}
+
+	ignoreMu.Lock()
+	defer ignoreMu.Unlock()
+	if cached, ok := ignoreCache[file]; ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.rules, nil
+	}
+	content, err := FS.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", displayPath(file), err)
+	}
+	patterns, err := parseIgnore(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", displayPath(file), err)
+	}
+	rules := &rootIgnore{root: root, realRoot: realRoot, patterns: patterns}
+	ignoreCache[file] = ignoreCacheEntry{modTime: info.ModTime(), size: info.Size(), rules: rules}
+	return rules, nil
+}
+
+// parseIgnore parses the lines of an ignore file into patterns split at slashes.
+// Blank lines and lines starting with # are skipped.
+func parseIgnore(content []byte) ([][]string, error) {
+	var patterns [][]string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSuffix(line, "/")
+		// Like in .gitignore, a pattern with a slash is relative to the root, and one without matches at any depth.
+		if !strings.Contains(line, "/") {
+			line = "**/" + line
+		}
+		line = strings.TrimPrefix(line, "/")
+		if _, err := path.Match(line, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern on line %d: %w", lineNumber, err)
+		}
+		patterns = append(patterns, strings.Split(line, "/"))
+	}
+	return patterns, scanner.Err()
+}
+
+// matches reports whether the absolute path p, or a directory containing it, is ignored by the
+// ignore file of any root it is in. The ignore files, the workspace file that lists the roots, and
+// the config file always are, so that the model can't lift the rules.
+func (r *ignoreRules) matches(p string) bool {
+	if slices.Contains(r.protected, p) {
+		return true
+	}
+	for _, root := range r.roots {
+		if root.matches(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether the absolute path p is below the root and ignored by its rules
+func (r *rootIgnore) matches(p string) bool {
+	rel, ok := r.relative(p)
+	if !ok {
+		return false
+	}
+	if rel == IgnoreFileName {
+		return true
+	}
+	segments := strings.Split(rel, "/")
+	for _, pattern := range r.patterns {
+		for i := 1; i <= len(segments); i++ {
+			if matchSegments(pattern, segments[:i]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// relative returns p relative to the root with / as separator, or false if p is the root or outside it
+func (r *rootIgnore) relative(p string) (string, bool) {
+	for _, root := range []string{r.root, r.realRoot} {
+		rel, err := filepath.Rel(root, p)
+		if err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return filepath.ToSlash(rel), true
+		}
+	}
+	return "", false
+}
+
+// blocks reports whether p or, if p is a symlink, the file it points to is ignored
+func (r *ignoreRules) blocks(p string) bool {
+	if r.matches(p) {
+		return true
+	}
+	real, err := FS.EvalSymlinks(p)
+	return err == nil && r.matches(real)
+}
+
+// check returns an error naming the path as name if the resolved path p is blocked
+func (r *ignoreRules) check(p, name string) error {
+	if r.blocks(p) {
+		return agent.NewToolError(agent.ToolErrorIgnored, "%s is blocked by %s", name, IgnoreFileName)
+	}
+	return nil
+}
+
+// checkTree returns an error naming the directory p as name if anything below it is ignored, or
+// would be once the directory is moved to dest. dest is empty if the directory is only removed.
+func (r *ignoreRules) checkTree(ctx context.Context, p, dest, name string) error {
+	return walkDir(p, func(entry string, _ fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(p, entry)
+		if err != nil {
+			return err
+		}
+		if r.matches(entry) || dest != "" && r.matches(filepath.Join(dest, rel)) {
+			return agent.NewToolError(agent.ToolErrorIgnored, "%s contains %s, which is blocked by %s", name, filepath.ToSlash(filepath.Join(name, rel)), IgnoreFileName)
+		}
+		return nil
+	})
+}
+
+// skipEntry is the result for filepath.Walk and WalkDir functions that skips an entry,
+// along with its contents if it is a directory
+func skipEntry(isDir bool) error {
+	if isDir {
+		return filepath.SkipDir
+	}
+	return nil
+}
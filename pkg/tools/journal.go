@@ -0,0 +1,82 @@
+package tools
+
+import (
+	"slices"
+	"sync"
+)
+
+// DefaultJournal records the files tools change, once begun. It implements agent.Transaction for the
+// changes that undo can revert.
+var DefaultJournal = &Journal{}
+
+// Journal keeps the content files had before they were changed since Begin, so that Rollback can restore them
+type Journal struct {
+	mu        sync.Mutex
+	active    bool
+	snapshots []fileSnapshot
+	// undos counts the undo entries recorded since Begin, which Rollback discards
+	undos int
+}
+
+// Begin starts recording changes
+func (j *Journal) Begin() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.active, j.snapshots, j.undos = true, nil, 0
+}
+
+// Commit stops recording and keeps the changes
+func (j *Journal) Commit() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.active, j.snapshots, j.undos = false, nil, 0
+}
+
+// Rollback stops recording and restores every file changed since Begin, returning their paths relative to RootDir
+func (j *Journal) Rollback() ([]string, error) {
+	j.mu.Lock()
+	snapshots, undos := j.snapshots, j.undos
+	j.active, j.snapshots, j.undos = false, nil, 0
+	j.mu.Unlock()
+
+	defer lockPaths(snapshotLockPaths(snapshots)...)()
+
+	// Undoing the changes in reverse order returns every path to the state it had at Begin, even
+	// if it changed several times, e.g. a directory that was created, renamed, and created again.
+	var restored []string
+	for _, snapshot := range slices.Backward(snapshots) {
+		if err := snapshot.restore(); err != nil {
+			return restored, err
+		}
+		if p := rootRelPath(snapshot.path); !slices.Contains(restored, p) {
+			restored = append(restored, p)
+		}
+	}
+
+	// The undo entries of the rolled back changes would now restore the same content again.
+	undoMu.Lock()
+	undoStack = undoStack[:len(undoStack)-min(undos, len(undoStack))]
+	undoMu.Unlock()
+	slices.Reverse(restored)
+	return restored, nil
+}
+
+// Active reports whether the journal is recording
+func (j *Journal) Active() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.active
+}
+
+// record keeps the snapshots of a change, if the journal is recording
+func (j *Journal) record(snapshots []fileSnapshot) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if !j.active {
+		return
+	}
+	if RecordUndo {
+		j.undos++
+	}
+	j.snapshots = append(j.snapshots, snapshots...)
+}
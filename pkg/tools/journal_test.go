@@ -0,0 +1,179 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// tree describes every file and directory below RootDir with its mode and content, to compare states
+func tree(t *testing.T) map[string]string {
+	t.Helper()
+	entries := map[string]string{}
+	err := walkDir(RootDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(RootDir, p)
+		if err != nil {
+			return err
+		}
+		entries[filepath.ToSlash(rel)] = fmt.Sprintf("%v", info.Mode())
+		if d.Type().IsRegular() {
+			content, err := FS.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			entries[filepath.ToSlash(rel)] += " " + string(content)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+// toolStep is a tool call made in a test
+type toolStep struct {
+	function func(context.Context, json.RawMessage) (string, error)
+	input    any
+}
+
+func TestJournalRollback(t *testing.T) {
+	tests := []struct {
+		name  string
+		trash bool
+		steps []toolStep
+	}{
+		{name: "make directory", steps: []toolStep{
+			{MakeDirectory, MakeDirectoryInput{Path: "new/nested/dir"}},
+			{WriteFile, WriteFileInput{Path: "new/nested/dir/a.txt", Content: "a"}},
+		}},
+		{name: "rename directory", steps: []toolStep{
+			{EditFile, EditFileInput{Path: "src/main.go", OldStr: "main", NewStr: "lib"}},
+			{RenameDirectory, RenameDirectoryInput{OldPath: "src", NewPath: "lib"}},
+			{WriteFile, WriteFileInput{Path: "lib/extra.go", Content: "package lib"}},
+			{MakeDirectory, MakeDirectoryInput{Path: "src"}},
+		}},
+		{name: "remove directory", steps: []toolStep{
+			{RemoveDirectory, RemoveDirectoryInput{Path: "src", Recursive: true}},
+			{WriteFile, WriteFileInput{Path: "src/main.go", Content: "package replaced"}},
+		}},
+		{name: "remove directory to trash", trash: true, steps: []toolStep{
+			{RemoveDirectory, RemoveDirectoryInput{Path: "src", Recursive: true}},
+		}},
+		{name: "remove empty directory", steps: []toolStep{
+			{RemoveDirectory, RemoveDirectoryInput{Path: "empty"}},
+		}},
+		{name: "chmod", steps: []toolStep{
+			{Chmod, ChmodInput{Path: "run.sh", Mode: "0600"}},
+			{Chmod, ChmodInput{Path: "src", Mode: "0700"}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMemFS(t)
+			writeFiles(t, map[string]string{
+				"src/main.go":     "package main",
+				"src/pkg/util.go": "package pkg",
+				"run.sh":          "#!/bin/sh",
+				"empty/.keep":     "",
+			})
+			if err := FS.Chmod(filepath.Join(RootDir, "run.sh"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := FS.Remove(filepath.Join(RootDir, "empty/.keep")); err != nil {
+				t.Fatal(err)
+			}
+			before := tree(t)
+			oldTrash := Trash
+			Trash = tt.trash
+			t.Cleanup(func() { Trash = oldTrash })
+
+			journal := &Journal{}
+			oldJournal := DefaultJournal
+			DefaultJournal = journal
+			t.Cleanup(func() { DefaultJournal = oldJournal })
+			journal.Begin()
+			for _, step := range tt.steps {
+				if _, err := callTool(t, step.function, step.input); err != nil {
+					t.Fatalf("%T: %v", step.input, err)
+				}
+			}
+			// The response then fails partway, so all of it is rolled back.
+			if _, err := callTool(t, EditFile, EditFileInput{Path: "run.sh", OldStr: "missing", NewStr: "x"}); err == nil {
+				t.Fatal("edit_file with a missing old_str succeeded")
+			}
+			if _, err := journal.Rollback(); err != nil {
+				t.Fatalf("Rollback() = %v", err)
+			}
+
+			if after := tree(t); !maps.Equal(after, before) {
+				t.Errorf("after rollback:\n%v\nwant:\n%v", after, before)
+			}
+		})
+	}
+}
+
+func TestUndoDirectoryTools(t *testing.T) {
+	tests := []struct {
+		name string
+		step toolStep
+	}{
+		{name: "make directory", step: toolStep{MakeDirectory, MakeDirectoryInput{Path: "a/b/c"}}},
+		{name: "rename directory", step: toolStep{RenameDirectory, RenameDirectoryInput{OldPath: "src", NewPath: "lib"}}},
+		{name: "remove directory", step: toolStep{RemoveDirectory, RemoveDirectoryInput{Path: "src", Recursive: true}}},
+		{name: "chmod", step: toolStep{Chmod, ChmodInput{Path: "src/main.go", Mode: "0700"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMemFS(t)
+			writeFiles(t, map[string]string{"src/main.go": "package main", "src/pkg/util.go": "package pkg"})
+			before := tree(t)
+			oldStack := undoStack
+			undoStack = nil
+			t.Cleanup(func() { undoStack = oldStack })
+
+			if _, err := callTool(t, tt.step.function, tt.step.input); err != nil {
+				t.Fatal(err)
+			}
+			if reflect.DeepEqual(tree(t), before) {
+				t.Fatal("the tool changed nothing")
+			}
+			if _, err := callTool(t, Undo, UndoInput{}); err != nil {
+				t.Fatalf("undo = %v", err)
+			}
+			if after := tree(t); !maps.Equal(after, before) {
+				t.Errorf("after undo:\n%v\nwant:\n%v", after, before)
+			}
+		})
+	}
+}
+
+func TestUndoSymlink(t *testing.T) {
+	useTempDir(t)
+	writeFiles(t, map[string]string{"a.txt": "a"})
+	before := tree(t)
+
+	if _, err := callTool(t, Symlink, SymlinkInput{Target: "../a.txt", LinkPath: "links/a"}); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+	if _, err := callTool(t, Undo, UndoInput{}); err != nil {
+		t.Fatalf("undo = %v", err)
+	}
+	if after := tree(t); !maps.Equal(after, before) {
+		t.Errorf("after undo:\n%v\nwant:\n%v", after, before)
+	}
+}
@@ -0,0 +1,53 @@
+package tools
+
+import (
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+var (
+	pathLocksMu sync.Mutex
+	// pathLocks holds a mutex per file that a tool has read-modify-written. Entries are never removed,
+	// which is fine for the number of files changed in a session.
+	pathLocks = map[string]*sync.Mutex{}
+)
+
+// lockPaths serializes changes to the files at paths, so that tool calls running at the same time, such as
+// a timed-out call that is still running, can't lose each other's edits. Paths are locked in sorted order
+// so that calls locking overlapping sets can't deadlock. It returns a function that unlocks them.
+func lockPaths(paths ...string) func() {
+	keys := make([]string, len(paths))
+	for i, p := range paths {
+		keys[i] = lockKey(p)
+	}
+	slices.Sort(keys)
+	keys = slices.Compact(keys)
+
+	mutexes := make([]*sync.Mutex, len(keys))
+	pathLocksMu.Lock()
+	for i, key := range keys {
+		if pathLocks[key] == nil {
+			pathLocks[key] = &sync.Mutex{}
+		}
+		mutexes[i] = pathLocks[key]
+	}
+	pathLocksMu.Unlock()
+
+	for _, mu := range mutexes {
+		mu.Lock()
+	}
+	return func() {
+		for i := len(mutexes) - 1; i >= 0; i-- {
+			mutexes[i].Unlock()
+		}
+	}
+}
+
+// lockKey identifies a file by its real path, so that a symlink and its target share a lock
+func lockKey(p string) string {
+	if real, err := FS.EvalSymlinks(p); err == nil {
+		return real
+	}
+	return filepath.Clean(p)
+}
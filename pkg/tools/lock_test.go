@@ -0,0 +1,44 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentEdits(t *testing.T) {
+	const edits = 50
+	useMemFS(t)
+	oldStack := undoStack
+	t.Cleanup(func() { undoStack = oldStack })
+
+	var lines []string
+	for i := range edits {
+		lines = append(lines, fmt.Sprintf("line %d;", i))
+	}
+	writeFiles(t, map[string]string{"a.txt": strings.Join(lines, "\n")})
+
+	var wg sync.WaitGroup
+	errs := make([]error, edits)
+	for i := range edits {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = callTool(t, EditFile, EditFileInput{Path: "a.txt", OldStr: fmt.Sprintf("line %d;", i), NewStr: fmt.Sprintf("edit %d;", i)})
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("edit %d: %v", i, err)
+		}
+	}
+
+	content := readFile(t, "a.txt")
+	for i := range edits {
+		if !strings.Contains(content, fmt.Sprintf("edit %d;", i)) {
+			t.Errorf("edit %d was lost", i)
+		}
+	}
+}
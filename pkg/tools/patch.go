@@ -0,0 +1,395 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/MarkusZoppelt/oen/pkg/agent"
+)
+
+func init() {
+	agent.DefaultRegistry.Register(ApplyPatchDefinition)
+}
+
+// ApplyPatchDefinition allows editing files by applying a unified diff
+var ApplyPatchDefinition = agent.ToolDefinition{
+	Name:                 "apply_patch",
+	Description:          "Apply a unified diff, as produced by diff -u or git diff, to one or more files. Each file starts with --- and +++ lines naming it (a/ and b/ prefixes are fine, /dev/null creates or deletes a file), followed by @@ hunks with context lines. The context and removed lines must match the files exactly, but may be found away from the line numbers given. Either every hunk applies or no file is changed. Use this for edits spanning several places or files.",
+	InputSchema:          GenerateSchema[ApplyPatchInput](),
+	Function:             ApplyPatch,
+	RequiresConfirmation: true,
+	ChangedPaths:         patchedPaths,
+}
+
+// ApplyPatchInput holds input for apply_patch tool
+type ApplyPatchInput struct {
+	Diff string `json:"diff" jsonschema_description:"The unified diff to apply, with paths relative to the working directory."`
+}
+
+// filePatch is the part of a diff that changes a single file
+type filePatch struct {
+	oldPath, newPath string
+	hunks            []hunk
+}
+
+// hunk is a single @@ section of a diff
+type hunk struct {
+	// oldStart is the 1-based line the hunk starts at in the old file, as given in its header
+	oldStart int
+	oldLines []string
+	newLines []string
+	// oldNoNewline and newNoNewline record a "\ No newline at end of file" marker on either side
+	oldNoNewline, newNoNewline bool
+}
+
+// patchedFile is the outcome of applying a filePatch, computed before anything is written
+type patchedFile struct {
+	path    string
+	name    string
+	existed bool
+	deleted bool
+	// original and mode are the file's state before the patch, to restore it if writing another file fails
+	original []byte
+	mode     os.FileMode
+	content  string
+	hunks    int
+}
+
+// hunkHeader matches the header of a hunk, e.g. "@@ -12,7 +12,8 @@ func main() {". Counts of 1 may be left out.
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+\d+(?:,(\d+))? @@`)
+
+// ApplyPatch applies a unified diff to the files it names, changing either all of them or none
+func ApplyPatch(ctx context.Context, input json.RawMessage) (string, error) {
+	var in ApplyPatchInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", err
+	}
+	patches, err := parsePatch(in.Diff)
+	if err != nil {
+		return "", err
+	}
+
+	paths := make([]string, len(patches))
+	for i, patch := range patches {
+		if paths[i], err = resolvePath(patch.name()); err != nil {
+			return "", err
+		}
+	}
+	defer lockPaths(paths...)()
+
+	files := make([]patchedFile, len(patches))
+	for i, patch := range patches {
+		if files[i], err = patch.apply(paths[i]); err != nil {
+			return "", err
+		}
+	}
+
+	var summary []string
+	for _, file := range files {
+		switch {
+		case file.deleted:
+			summary = append(summary, file.name+" (deleted)")
+		case !file.existed:
+			summary = append(summary, file.name+" (created)")
+		default:
+			summary = append(summary, fmt.Sprintf("%s (%d hunks)", file.name, file.hunks))
+		}
+	}
+	if DryRun {
+		return dryRunf("Would patch %d files: %s", len(files), strings.Join(summary, ", ")), nil
+	}
+
+	var backups []string
+	for _, file := range files {
+		if !file.existed {
+			continue
+		}
+		backup, err := backupFile(file.path)
+		if err != nil {
+			return "", err
+		}
+		if backup != "" {
+			backups = append(backups, displayPath(backup))
+		}
+	}
+	if err := recordUndo(paths...); err != nil {
+		return "", err
+	}
+	if err := writePatchedFiles(files); err != nil {
+		return "", err
+	}
+
+	result := fmt.Sprintf("Patched %d files: %s", len(files), strings.Join(summary, ", "))
+	if len(backups) > 0 {
+		result += fmt.Sprintf(" (backups saved to %s)", strings.Join(backups, ", "))
+	}
+	return result, nil
+}
+
+// writePatchedFiles writes the patched files. If one fails, the files written before it are restored.
+func writePatchedFiles(files []patchedFile) error {
+	for i, file := range files {
+		var err error
+		switch {
+		case file.deleted:
+			err = FS.Remove(file.path)
+		case !file.existed:
+			err = createNewFile(file.path, file.content)
+		default:
+			err = writeFileAtomic(file.path, []byte(file.content))
+		}
+		if err == nil {
+			continue
+		}
+		for _, written := range files[:i] {
+			if written.existed {
+				writeFileAtomic(written.path, written.original)
+				FS.Chmod(written.path, written.mode)
+			} else {
+				FS.Remove(written.path)
+			}
+		}
+		return fmt.Errorf("failed to patch %s, no file was changed: %w", file.name, err)
+	}
+	return nil
+}
+
+// parsePatch splits a unified diff into the changes to each file. Lines outside of files and hunks,
+// such as "diff --git" and "index" lines, are skipped.
+func parsePatch(diff string) ([]filePatch, error) {
+	lines := strings.Split(strings.ReplaceAll(diff, "\r\n", "\n"), "\n")
+	var patches []filePatch
+	for i := 0; i < len(lines); i++ {
+		if !strings.HasPrefix(lines[i], "--- ") || i+1 == len(lines) || !strings.HasPrefix(lines[i+1], "+++ ") {
+			continue
+		}
+		patch := filePatch{oldPath: diffPath(lines[i][4:]), newPath: diffPath(lines[i+1][4:])}
+		if patch.oldPath != "/dev/null" && patch.newPath != "/dev/null" && patch.oldPath != patch.newPath {
+			return nil, agent.NewToolError(agent.ToolErrorInvalidInput, "renaming %s to %s is not supported; use move_file and patch the file under its new name", patch.oldPath, patch.newPath)
+		}
+		if patch.oldPath == "/dev/null" && patch.newPath == "/dev/null" {
+			return nil, agent.NewToolError(agent.ToolErrorInvalidInput, "--- and +++ can't both be /dev/null")
+		}
+		i += 2
+		for i < len(lines) && strings.HasPrefix(lines[i], "@@") {
+			h, next, err := parseHunk(lines, i)
+			if err != nil {
+				return nil, fmt.Errorf("%w in the diff of %s", err, patch.name())
+			}
+			patch.hunks = append(patch.hunks, h)
+			i = next
+		}
+		if len(patch.hunks) == 0 {
+			return nil, agent.NewToolError(agent.ToolErrorInvalidInput, "the diff of %s has no @@ hunks", patch.name())
+		}
+		patches = append(patches, patch)
+		i--
+	}
+	if len(patches) == 0 {
+		return nil, agent.NewToolError(agent.ToolErrorInvalidInput, "no file changes found; the diff must contain --- and +++ lines followed by @@ hunks")
+	}
+	seen := map[string]bool{}
+	for _, patch := range patches {
+		if seen[patch.name()] {
+			return nil, agent.NewToolError(agent.ToolErrorInvalidInput, "%s is changed twice; combine its hunks under a single --- and +++ header", patch.name())
+		}
+		seen[patch.name()] = true
+	}
+	return patches, nil
+}
+
+// parseHunk parses the hunk whose header is lines[start] and returns it with the index of the line after it.
+// The hunk ends once it has as many lines as its header says, or earlier at the next header.
+func parseHunk(lines []string, start int) (hunk, int, error) {
+	match := hunkHeader.FindStringSubmatch(lines[start])
+	if match == nil {
+		return hunk{}, 0, agent.NewToolError(agent.ToolErrorInvalidInput, "invalid hunk header %q", lines[start])
+	}
+	h := hunk{}
+	h.oldStart, _ = strconv.Atoi(match[1])
+	oldCount, newCount := 1, 1
+	if match[2] != "" {
+		oldCount, _ = strconv.Atoi(match[2])
+	}
+	if match[3] != "" {
+		newCount, _ = strconv.Atoi(match[3])
+	}
+
+	i := start + 1
+	var last byte
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		kind := byte(' ')
+		if line != "" {
+			kind, line = line[0], line[1:]
+		}
+		if kind == '\\' {
+			// "\ No newline at end of file" refers to the line before it.
+			h.oldNoNewline = h.oldNoNewline || last != '+'
+			h.newNoNewline = h.newNoNewline || last != '-'
+			continue
+		}
+		if len(h.oldLines) >= oldCount && len(h.newLines) >= newCount || isDiffHeader(lines, i) {
+			break
+		}
+		switch kind {
+		case ' ':
+			h.oldLines = append(h.oldLines, line)
+			h.newLines = append(h.newLines, line)
+		case '-':
+			h.oldLines = append(h.oldLines, line)
+		case '+':
+			h.newLines = append(h.newLines, line)
+		default:
+			return h, i, nil
+		}
+		last = kind
+	}
+
+	if len(h.oldLines) < oldCount || len(h.newLines) < newCount {
+		// An empty last line is the newline ending the diff, not an empty context line.
+		for i == len(lines) && last == ' ' && len(h.newLines) > 0 && h.newLines[len(h.newLines)-1] == "" {
+			h.oldLines = h.oldLines[:len(h.oldLines)-1]
+			h.newLines = h.newLines[:len(h.newLines)-1]
+		}
+	} else if i < len(lines) && lines[i] != "" && strings.ContainsRune(" -+", rune(lines[i][0])) && !isDiffHeader(lines, i) {
+		return h, 0, agent.NewToolError(agent.ToolErrorInvalidInput, "hunk %q has more lines than its header says", lines[start])
+	}
+	return h, i, nil
+}
+
+// isDiffHeader reports whether lines[i] starts a hunk or the diff of another file
+func isDiffHeader(lines []string, i int) bool {
+	return strings.HasPrefix(lines[i], "@@") ||
+		strings.HasPrefix(lines[i], "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ")
+}
+
+// diffPath extracts the path from a --- or +++ line, dropping a trailing timestamp and git's a/ or b/ prefix
+func diffPath(s string) string {
+	if tab := strings.IndexByte(s, '\t'); tab != -1 {
+		s = s[:tab]
+	}
+	s = strings.TrimSpace(s)
+	if s == "/dev/null" {
+		return s
+	}
+	if strings.HasPrefix(s, "a/") || strings.HasPrefix(s, "b/") {
+		s = s[2:]
+	}
+	return s
+}
+
+// name returns the path of the file the patch changes
+func (p filePatch) name() string {
+	if p.newPath == "/dev/null" {
+		return p.oldPath
+	}
+	return p.newPath
+}
+
+// apply applies the hunks to the file at path, which the patch names, and returns the new content
+func (p filePatch) apply(path string) (patchedFile, error) {
+	file := patchedFile{path: path, name: p.name(), hunks: len(p.hunks), deleted: p.newPath == "/dev/null"}
+	content, err := FS.ReadFile(path)
+	switch {
+	case err == nil:
+		file.existed, file.original, file.mode = true, content, fileMode(path)
+		if p.oldPath == "/dev/null" {
+			return file, agent.NewToolError(agent.ToolErrorAlreadyExists, "%s already exists, but the diff creates it", file.name)
+		}
+	case os.IsNotExist(err) && p.oldPath == "/dev/null":
+	default:
+		return file, err
+	}
+
+	lines := splitLines(string(content))
+	endsWithNewline := len(content) == 0 || strings.HasSuffix(string(content), "\n")
+	var result []string
+	// pos is the number of lines of the old file consumed so far.
+	pos := 0
+	for n, h := range p.hunks {
+		at := findHunk(lines, h.oldLines, pos, h.oldStart-1)
+		if at == -1 {
+			return file, agent.NewToolError(agent.ToolErrorInvalidInput, "hunk %d of %s does not match the file at or after line %d; read the file and make sure the context and removed lines match exactly", n+1, file.name, pos+1)
+		}
+		result = append(result, lines[pos:at]...)
+		result = append(result, h.newLines...)
+		pos = at + len(h.oldLines)
+		if pos == len(lines) {
+			switch {
+			case h.newNoNewline:
+				endsWithNewline = false
+			case h.oldNoNewline:
+				endsWithNewline = true
+			}
+		}
+	}
+	result = append(result, lines[pos:]...)
+
+	if file.deleted {
+		if len(result) > 0 {
+			return file, agent.NewToolError(agent.ToolErrorInvalidInput, "the diff deletes %s, but doesn't remove all of its lines", file.name)
+		}
+		return file, nil
+	}
+	file.content = strings.Join(result, "\n")
+	if len(result) > 0 && endsWithNewline {
+		file.content += "\n"
+	}
+	return file, nil
+}
+
+// findHunk returns the index at which old occurs in lines at or after from, preferring the
+// occurrence closest to hint, or -1 if it doesn't occur
+func findHunk(lines, old []string, from, hint int) int {
+	if len(old) == 0 {
+		// A hunk that only adds lines goes where its header says.
+		return min(max(hint+1, from), len(lines))
+	}
+	best := -1
+	for i := from; i+len(old) <= len(lines); i++ {
+		if !slices.Equal(lines[i:i+len(old)], old) {
+			continue
+		}
+		if best == -1 || abs(i-hint) < abs(best-hint) {
+			best = i
+		}
+	}
+	return best
+}
+
+// abs returns the absolute value of n
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// patchedPaths reports the files an apply_patch call changed, as named in its diff
+func patchedPaths(input json.RawMessage, _ string) []string {
+	if DryRun {
+		return nil
+	}
+	var in ApplyPatchInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return nil
+	}
+	patches, err := parsePatch(in.Diff)
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	for _, patch := range patches {
+		if p, err := resolvePath(patch.name()); err == nil {
+			paths = append(paths, rootRelPath(p))
+		}
+	}
+	return paths
+}
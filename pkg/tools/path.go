@@ -0,0 +1,305 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/MarkusZoppelt/oen/pkg/agent"
+)
+
+// RootDir is the directory that all tool paths are confined to, along with any WorkspaceRoots
+var RootDir = "."
+
+// WorkspaceRoots are further directories tool paths may lead into, e.g. sibling modules of a monorepo.
+// Relative paths are still resolved against the working directory, so e.g. ../api reaches a sibling root.
+var WorkspaceRoots []string
+
+// WorkspaceFileName is the file in RootDir that lists WorkspaceRoots, one directory per line
+const WorkspaceFileName = ".oen-workspace"
+
+// ErrPathEscape is returned when a tool path resolves outside RootDir and the WorkspaceRoots
+var ErrPathEscape error = agent.NewToolError(agent.ToolErrorPathEscape, "path escapes the working directory")
+
+var (
+	workDirMu sync.RWMutex
+	// workDir is the directory relative tool paths are resolved against, relative to RootDir
+	workDir = "."
+)
+
+// WorkDir returns the absolute directory that relative tool paths are currently resolved against
+func WorkDir() (string, error) {
+	root, err := filepath.Abs(RootDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve root directory: %w", err)
+	}
+	workDirMu.RLock()
+	defer workDirMu.RUnlock()
+	return filepath.Join(root, workDir), nil
+}
+
+// SetWorkDir changes the directory relative tool paths are resolved against.
+// p is itself resolved like any tool path and must be a directory inside RootDir.
+func SetWorkDir(p string) (string, error) {
+	dir, err := resolvePath(p)
+	if err != nil {
+		return "", err
+	}
+	info, err := FS.Stat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "%s is not a directory", p)
+	}
+	root, err := filepath.Abs(RootDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve root directory: %w", err)
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return "", err
+	}
+
+	workDirMu.Lock()
+	defer workDirMu.Unlock()
+	workDir = rel
+	return dir, nil
+}
+
+// LoadWorkspace returns the directories listed in WorkspaceFileName in RootDir as absolute paths, or nothing
+// if there is no such file. Relative paths are relative to RootDir; blank lines and lines starting with # are skipped.
+func LoadWorkspace() ([]string, error) {
+	root, err := filepath.Abs(RootDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve root directory: %w", err)
+	}
+	content, err := FS.ReadFile(filepath.Join(root, WorkspaceFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", WorkspaceFileName, err)
+	}
+	var dirs []string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		dir := line
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(root, dir)
+		}
+		info, err := FS.Stat(dir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid workspace root %s in %s: %w", line, WorkspaceFileName, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("invalid workspace root %s in %s: not a directory", line, WorkspaceFileName)
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs, nil
+}
+
+// SetRoots sets RootDir to root, unless root is empty, and WorkspaceRoots to the directories listed in
+// WorkspaceFileName in the new RootDir followed by extra, e.g. those of the config file. A directory
+// listed more than once, or that is RootDir itself, is only kept once.
+func SetRoots(root string, extra []string) error {
+	if root != "" {
+		info, err := FS.Stat(root)
+		if err != nil {
+			return fmt.Errorf("invalid root %s: %w", root, err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("invalid root %s: not a directory", root)
+		}
+		RootDir = root
+	}
+	workspace, err := LoadWorkspace()
+	if err != nil {
+		return err
+	}
+	base, err := filepath.Abs(RootDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve root directory: %w", err)
+	}
+	seen := map[string]bool{base: true}
+	var dirs []string
+	for _, dir := range append(workspace, extra...) {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return fmt.Errorf("failed to resolve workspace root: %w", err)
+		}
+		if !seen[abs] {
+			seen[abs] = true
+			dirs = append(dirs, abs)
+		}
+	}
+	WorkspaceRoots = dirs
+	return nil
+}
+
+// roots returns the absolute paths of RootDir and the WorkspaceRoots
+func roots() ([]string, error) {
+	root, err := filepath.Abs(RootDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve root directory: %w", err)
+	}
+	all := []string{root}
+	for _, dir := range WorkspaceRoots {
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve workspace root: %w", err)
+		}
+		all = append(all, dir)
+	}
+	return all, nil
+}
+
+// insideRoots reports whether the absolute path p is one of the roots or inside one
+func insideRoots(roots []string, p string) bool {
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, p)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRoot reports whether the absolute path p is RootDir or one of the WorkspaceRoots, by its name or through a symlink
+func isRoot(p string) (bool, error) {
+	all, err := roots()
+	if err != nil {
+		return false, err
+	}
+	real, err := FS.EvalSymlinks(p)
+	if err != nil {
+		real = p
+	}
+	for _, root := range all {
+		if realRoot, err := FS.EvalSymlinks(root); err == nil && realRoot == real {
+			return true, nil
+		}
+		if root == p {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// resolvePath cleans p, joins it to the working directory, and rejects it if it ends up outside RootDir
+// and the WorkspaceRoots, either by its name or through a symlink
+func resolvePath(p string) (string, error) {
+	all, err := roots()
+	if err != nil {
+		return "", err
+	}
+	base, err := WorkDir()
+	if err != nil {
+		return "", err
+	}
+
+	resolved := filepath.Clean(p)
+	if !filepath.IsAbs(resolved) {
+		resolved = filepath.Join(base, resolved)
+	}
+
+	if !insideRoots(all, resolved) {
+		return "", fmt.Errorf("%w: %s", ErrPathEscape, p)
+	}
+	if err := checkRealPath(resolved, p); err != nil {
+		return "", err
+	}
+	ignore, err := loadIgnoreRules()
+	if err != nil {
+		return "", err
+	}
+	if err := ignore.check(resolved, p); err != nil {
+		return "", err
+	}
+	return resolved, nil
+}
+
+// maxSymlinks bounds how many symlinks realPath follows, to stop at link cycles
+const maxSymlinks = 40
+
+// checkRealPath returns ErrPathEscape, naming the path as name, if p really leads outside RootDir and
+// the WorkspaceRoots once symlinks are resolved. Such links are only followed if AllowExternalSymlinks is set.
+func checkRealPath(p, name string) error {
+	if AllowExternalSymlinks {
+		return nil
+	}
+	all, err := roots()
+	if err != nil {
+		return err
+	}
+	// The roots may themselves be reached through symlinks, e.g. /tmp on macOS.
+	realRoots := make([]string, 0, len(all))
+	for _, root := range all {
+		if real, err := FS.EvalSymlinks(root); err == nil {
+			root = real
+		}
+		realRoots = append(realRoots, root)
+	}
+	real, err := realPath(p, 0)
+	if err != nil {
+		return err
+	}
+	if !insideRoots(realRoots, real) {
+		return fmt.Errorf("%w: %s leads to %s", ErrPathEscape, name, real)
+	}
+	return nil
+}
+
+// realPath returns the absolute path p with all symlinks resolved, including a dangling link that
+// creating p would follow. The part of p that doesn't exist yet is kept as it is. links counts the
+// symlinks followed so far.
+func realPath(p string, links int) (string, error) {
+	rest := ""
+	for dir := p; ; dir = filepath.Dir(dir) {
+		real, err := FS.EvalSymlinks(dir)
+		if err == nil {
+			return filepath.Join(real, rest), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		if info, err := FS.Lstat(dir); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+			if links >= maxSymlinks {
+				return "", fmt.Errorf("too many levels of symlinks in %s", displayPath(p))
+			}
+			target, err := FS.Readlink(dir)
+			if err != nil {
+				return "", err
+			}
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(dir), target)
+			}
+			return realPath(filepath.Join(target, rest), links+1)
+		}
+		if filepath.Dir(dir) == dir {
+			return p, nil
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+	}
+}
+
+// displayPath returns p relative to the working directory for reporting back to the model
+func displayPath(p string) string {
+	base, err := WorkDir()
+	if err != nil {
+		return p
+	}
+	rel, err := filepath.Rel(base, p)
+	if err != nil {
+		return p
+	}
+	return rel
+}
@@ -0,0 +1,297 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useMemFS points the tools at an empty root directory in a new MemFS for the rest of the test
+func useMemFS(t *testing.T) string {
+	t.Helper()
+	fsys := NewMemFS()
+	root := filepath.Join(string(filepath.Separator), "mem", t.Name())
+	if err := fsys.MkdirAll(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	setRoot(t, fsys, root)
+	return root
+}
+
+// useTempDir points the tools at a new temporary directory on disk for the rest of the test,
+// for tests that need what MemFS lacks, such as symlinks
+func useTempDir(t *testing.T) string {
+	t.Helper()
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	setRoot(t, OSFS{}, root)
+	return root
+}
+
+// setRoot replaces FS and RootDir and resets the other path settings until the test ends
+func setRoot(t *testing.T, fsys FileSystem, root string) {
+	t.Helper()
+	oldFS, oldRoot, oldWorkDir, oldRoots, oldExternal := FS, RootDir, workDir, WorkspaceRoots, AllowExternalSymlinks
+	FS, RootDir, workDir, WorkspaceRoots, AllowExternalSymlinks = fsys, root, ".", nil, false
+	t.Cleanup(func() {
+		FS, RootDir, workDir, WorkspaceRoots, AllowExternalSymlinks = oldFS, oldRoot, oldWorkDir, oldRoots, oldExternal
+	})
+}
+
+// writeFiles creates files with the given contents, by path relative to RootDir
+func writeFiles(t *testing.T, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(RootDir, name)
+		if err := FS.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := FS.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readFile returns the content of the file at the path relative to RootDir
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	content, err := FS.ReadFile(filepath.Join(RootDir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+// callTool runs a tool function with input marshaled to JSON
+func callTool(t *testing.T, function func(context.Context, json.RawMessage) (string, error), input any) (string, error) {
+	t.Helper()
+	data, err := json.Marshal(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return function(context.Background(), data)
+}
+
+// symlink creates a symlink at the path relative to RootDir
+func symlink(t *testing.T, target, name string) {
+	t.Helper()
+	if err := os.Symlink(target, filepath.Join(RootDir, name)); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+}
+
+func TestResolvePath(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		external bool
+		escapes  bool
+	}{
+		{name: "relative", path: "a.txt"},
+		{name: "dot dot inside", path: "sub/../a.txt"},
+		{name: "missing file", path: "sub/new/file.txt"},
+		{name: "dot dot", path: "../a.txt", escapes: true},
+		{name: "dot dot after directory", path: "sub/../../a.txt", escapes: true},
+		{name: "many dot dots", path: "../../../../../../etc/passwd", escapes: true},
+		{name: "absolute inside", path: "ROOT/a.txt"},
+		{name: "absolute root", path: "ROOT"},
+		{name: "absolute outside", path: "/etc/passwd", escapes: true},
+		{name: "absolute sibling with root as prefix", path: "ROOT-other/a.txt", escapes: true},
+		{name: "symlink inside", path: "in/b.txt"},
+		{name: "symlink to outside directory", path: "out/secret.txt", escapes: true},
+		{name: "symlink to outside file", path: "outfile", escapes: true},
+		{name: "missing file below symlink to outside", path: "out/new/file.txt", escapes: true},
+		{name: "dangling symlink to outside", path: "dangling", escapes: true},
+		{name: "chained symlinks to outside", path: "d/e/secret.txt", escapes: true},
+		{name: "symlink to outside allowed", path: "out/secret.txt", external: true},
+	}
+
+	root := useTempDir(t)
+	outside, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, map[string]string{"a.txt": "a", "sub/b.txt": "b"})
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	symlink(t, "sub", "in")
+	symlink(t, outside, "out")
+	symlink(t, filepath.Join(outside, "secret.txt"), "outfile")
+	symlink(t, filepath.Join(outside, "missing.txt"), "dangling")
+	// d/e is e, which points at the directory above the root.
+	symlink(t, ".", "d")
+	symlink(t, "..", "e")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			AllowExternalSymlinks = tt.external
+			defer func() { AllowExternalSymlinks = false }()
+			p := strings.Replace(tt.path, "ROOT", root, 1)
+			_, err := resolvePath(p)
+			if tt.escapes && !errors.Is(err, ErrPathEscape) {
+				t.Errorf("resolvePath(%q) = %v, want ErrPathEscape", p, err)
+			}
+			if !tt.escapes && err != nil {
+				t.Errorf("resolvePath(%q) = %v, want no error", p, err)
+			}
+		})
+	}
+}
+
+func TestToolsRejectEscapingPaths(t *testing.T) {
+	tests := []struct {
+		name     string
+		function func(context.Context, json.RawMessage) (string, error)
+		input    string
+	}{
+		{name: "read_file", function: ReadFile, input: `{"path":"../secret.txt"}`},
+		{name: "list_files", function: ListFiles, input: `{"path":"../"}`},
+		{name: "edit_file", function: EditFile, input: `{"path":"/etc/passwd","old_str":"root","new_str":"x"}`},
+		{name: "make_directory", function: MakeDirectory, input: `{"path":"../new"}`},
+		{name: "remove_directory", function: RemoveDirectory, input: `{"path":"sub/../.."}`},
+		{name: "rename_directory", function: RenameDirectory, input: `{"old_path":"sub","new_path":"../moved"}`},
+		{name: "move_file", function: MoveFile, input: `{"source":"sub/b.txt","dest":"../b.txt"}`},
+	}
+
+	useMemFS(t)
+	writeFiles(t, map[string]string{"sub/b.txt": "b"})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.function(context.Background(), json.RawMessage(tt.input)); !errors.Is(err, ErrPathEscape) {
+				t.Errorf("%s(%s) = %v, want ErrPathEscape", tt.name, tt.input, err)
+			}
+		})
+	}
+}
+
+func TestWriteThroughSymlinkToOutside(t *testing.T) {
+	useTempDir(t)
+	outside := filepath.Join(t.TempDir(), "target.txt")
+	if err := os.WriteFile(outside, []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	symlink(t, outside, "link")
+
+	if _, err := callTool(t, WriteFile, WriteFileInput{Path: "link", Content: "changed"}); !errors.Is(err, ErrPathEscape) {
+		t.Fatalf("write_file through link = %v, want ErrPathEscape", err)
+	}
+	if _, err := callTool(t, AppendFile, AppendFileInput{Path: "link", Content: "changed"}); !errors.Is(err, ErrPathEscape) {
+		t.Fatalf("append_to_file through link = %v, want ErrPathEscape", err)
+	}
+	if err := writeFileAtomic(filepath.Join(RootDir, "link"), []byte("changed")); !errors.Is(err, ErrPathEscape) {
+		t.Fatalf("writeFileAtomic through link = %v, want ErrPathEscape", err)
+	}
+	content, err := os.ReadFile(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "original" {
+		t.Errorf("file outside the root = %q, want it unchanged", content)
+	}
+}
+
+func TestSetRoots(t *testing.T) {
+	tests := []struct {
+		name string
+		// files are created below the test directory, which is RootDir by default
+		files map[string]string
+		// root and extra are passed to SetRoots with ROOT replaced by the test directory
+		root    string
+		extra   []string
+		want    []string
+		wantDir string
+		wantErr string
+	}{
+		{name: "default root without workspace", files: map[string]string{"a.txt": ""}, wantDir: "ROOT"},
+		{
+			name:    "workspace file",
+			files:   map[string]string{WorkspaceFileName: "../api\n# comment\n\n../web\n", "../api/a.go": "", "../web/b.go": ""},
+			want:    []string{"ROOT/../api", "ROOT/../web"},
+			wantDir: "ROOT",
+		},
+		{
+			name:    "config workspace",
+			files:   map[string]string{"../api/a.go": ""},
+			extra:   []string{"ROOT/../api"},
+			want:    []string{"ROOT/../api"},
+			wantDir: "ROOT",
+		},
+		{
+			name:    "workspace file before config",
+			files:   map[string]string{WorkspaceFileName: "../api\n", "../api/a.go": "", "../web/b.go": ""},
+			extra:   []string{"ROOT/../web"},
+			want:    []string{"ROOT/../api", "ROOT/../web"},
+			wantDir: "ROOT",
+		},
+		{
+			name:    "root listed in both",
+			files:   map[string]string{WorkspaceFileName: "../api\n.\n", "../api/a.go": ""},
+			extra:   []string{"ROOT/../api", "ROOT"},
+			want:    []string{"ROOT/../api"},
+			wantDir: "ROOT",
+		},
+		{
+			name: "config root replaces default root",
+			files: map[string]string{
+				WorkspaceFileName:               "../ignored\n",
+				"sub/" + WorkspaceFileName:      "../api\n",
+				"api/a.go":                      "",
+				"sub/main.go":                   "",
+				"../ignored/this-is-not-loaded": "",
+			},
+			root:    "ROOT/sub",
+			want:    []string{"ROOT/api"},
+			wantDir: "ROOT/sub",
+		},
+		{name: "missing config root", root: "ROOT/missing", wantErr: "invalid root"},
+		{name: "config root is a file", files: map[string]string{"file": ""}, root: "ROOT/file", wantErr: "not a directory"},
+		{name: "missing workspace root", files: map[string]string{WorkspaceFileName: "../missing\n"}, wantErr: "invalid workspace root"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := useMemFS(t)
+			writeFiles(t, tt.files)
+			expand := func(p string) string {
+				return filepath.Clean(strings.Replace(p, "ROOT", root, 1))
+			}
+			var extra []string
+			for _, dir := range tt.extra {
+				extra = append(extra, expand(dir))
+			}
+			rootArg := ""
+			if tt.root != "" {
+				rootArg = expand(tt.root)
+			}
+
+			err := SetRoots(rootArg, extra)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("SetRoots() = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SetRoots() = %v", err)
+			}
+			if RootDir != expand(tt.wantDir) {
+				t.Errorf("RootDir = %s, want %s", RootDir, expand(tt.wantDir))
+			}
+			var want []string
+			for _, dir := range tt.want {
+				want = append(want, expand(dir))
+			}
+			if strings.Join(WorkspaceRoots, ",") != strings.Join(want, ",") {
+				t.Errorf("WorkspaceRoots = %v, want %v", WorkspaceRoots, want)
+			}
+		})
+	}
+}
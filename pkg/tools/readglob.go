@@ -0,0 +1,192 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/MarkusZoppelt/oen/pkg/agent"
+)
+
+// maxGlobFiles is the most files read_glob reads in one call
+const maxGlobFiles = 100
+
+// maxGlobFileBytes is how much of each file read_glob returns
+const maxGlobFileBytes = 20000
+
+// maxGlobTotalBytes caps the file contents read_glob returns in total
+const maxGlobTotalBytes = 200000
+
+func init() {
+	agent.DefaultRegistry.Register(ReadGlobDefinition)
+}
+
+// ReadGlobDefinition allows reading every file that matches a pattern in one call
+var ReadGlobDefinition = agent.ToolDefinition{
+	Name:        "read_glob",
+	Description: "Read all files whose path matches a glob pattern, e.g. config/*.yaml or **/*.md, and return a JSON object mapping each path to its content. Use this instead of many read_file calls when you need a set of related files. Long files are truncated, binary files are skipped, and at most 100 files can match.",
+	InputSchema: GenerateSchema[ReadGlobInput](),
+	Function:    ReadGlob,
+	ReadOnly:    true,
+}
+
+// ReadGlobInput holds input for read_glob tool
+type ReadGlobInput struct {
+	Pattern string `json:"pattern" jsonschema_description:"A glob relative to the working directory, with / as separator. * and ? match within a path segment and ** matches any number of directories."`
+}
+
+// ReadGlob reads the files matching a glob pattern
+func ReadGlob(ctx context.Context, input json.RawMessage) (string, error) {
+	var in ReadGlobInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", err
+	}
+	if in.Pattern == "" {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "pattern must not be empty")
+	}
+	matches, err := globFiles(ctx, in.Pattern)
+	if err != nil {
+		return "", err
+	}
+	if len(matches) > maxGlobFiles {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "%s matches %d files, but at most %d can be read at once; use a narrower pattern", in.Pattern, len(matches), maxGlobFiles)
+	}
+
+	files := make(map[string]string, len(matches))
+	total := 0
+	for _, p := range matches {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		name := displayPath(p)
+		if total >= maxGlobTotalBytes {
+			files[name] = "[omitted, the total size limit was reached; read it separately]"
+			continue
+		}
+		content, err := FS.ReadFile(p)
+		if err != nil {
+			files[name] = fmt.Sprintf("[error: %s]", err)
+			continue
+		}
+		if looksBinary(content) {
+			files[name] = fmt.Sprintf("[binary file, %d bytes]", len(content))
+			continue
+		}
+		limit := min(maxGlobFileBytes, maxGlobTotalBytes-total)
+		text := string(content)
+		if len(text) > limit {
+			for limit > 0 && !utf8.RuneStart(text[limit]) {
+				limit--
+			}
+			text = fmt.Sprintf("%s\n[truncated, %d more bytes]", text[:limit], len(text)-limit)
+		}
+		total += min(len(content), limit)
+		files[name] = text
+	}
+
+	var result strings.Builder
+	encoder := json.NewEncoder(&result)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(files); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(result.String(), "\n"), nil
+}
+
+// globFiles returns the regular files matching a glob relative to the working directory, skipping
+// ignored paths and .git directories. It fails if no file matches.
+func globFiles(ctx context.Context, pattern string) ([]string, error) {
+	if path.IsAbs(pattern) {
+		return nil, agent.NewToolError(agent.ToolErrorInvalidInput, "pattern must be relative to the working directory")
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, agent.NewToolError(agent.ToolErrorInvalidInput, "invalid pattern: %w", err)
+	}
+
+	// Only walk the directory named by the segments before the first wildcard.
+	segments := strings.Split(path.Clean(pattern), "/")
+	static := 0
+	for static < len(segments)-1 && !strings.ContainsAny(segments[static], `*?[\`) {
+		static++
+	}
+	base, err := resolvePath(filepath.Join(segments[:static]...))
+	if err != nil {
+		return nil, err
+	}
+	patternSegments := segments[static:]
+	recursive := false
+	for _, segment := range patternSegments {
+		recursive = recursive || segment == "**"
+	}
+
+	ignore, err := loadIgnoreRules()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	err = walkDir(base, func(p string, d fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			if p == base && os.IsNotExist(err) {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		rel, err := filepath.Rel(base, p)
+		if err != nil {
+			return err
+		}
+		if p != base && ignore.matches(p) {
+			return skipEntry(d.IsDir())
+		}
+		if d.IsDir() {
+			if p != base && (d.Name() == ".git" || !recursive && strings.Count(rel, string(filepath.Separator))+1 >= len(patternSegments)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() && matchSegments(patternSegments, strings.Split(filepath.ToSlash(rel), "/")) {
+			matches = append(matches, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, agent.NewToolError(agent.ToolErrorNotFound, "no files match %s", pattern)
+	}
+	return matches, nil
+}
+
+// matchSegments reports whether the segments of a slash-separated path match the segments of a
+// glob pattern, where a ** segment matches any number of path segments, including none
+func matchSegments(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], name[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], name[1:])
+}
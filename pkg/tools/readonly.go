@@ -0,0 +1,19 @@
+package tools
+
+// ReadOnlyTools names the tools that only inspect files and never change them, for a read-only preset
+var ReadOnlyTools = []string{
+	"read_file",
+	"read_file_lines",
+	"tail_file",
+	"read_glob",
+	"list_files",
+	"get_file_info",
+	"disk_usage",
+	"count",
+	"search_files",
+	"directory_tree",
+	"git_diff",
+	"git_status",
+	"compare_files",
+	"change_directory",
+}
@@ -0,0 +1,101 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/MarkusZoppelt/oen/pkg/agent"
+)
+
+func init() {
+	agent.DefaultRegistry.Register(RegexReplaceDefinition)
+}
+
+// RegexReplaceDefinition allows patterned edits to a single file
+var RegexReplaceDefinition = agent.ToolDefinition{
+	Name:                 "regex_replace",
+	Description:          "Replace matches of a regular expression in a file, using $1 or ${name} in the replacement to refer to capture groups. Replaces every match unless count is given. Returns the number of replacements and a diff. Use this for patterned changes that edit_file's exact matching can't express.",
+	InputSchema:          GenerateSchema[RegexReplaceInput](),
+	Function:             RegexReplace,
+	RequiresConfirmation: true,
+	ChangedPaths:         changedInputPaths("path"),
+}
+
+// RegexReplaceInput holds input for regex_replace tool
+type RegexReplaceInput struct {
+	Path        string `json:"path" jsonschema_description:"The relative path of the file to edit."`
+	Pattern     string `json:"pattern" jsonschema_description:"The regular expression to match, in Go RE2 syntax. Use (?m) to make ^ and $ match at line boundaries."`
+	Replacement string `json:"replacement" jsonschema_description:"The replacement text. $1, ${1}, or ${name} refer to capture groups; $$ is a literal dollar sign."`
+	Count       int    `json:"count,omitempty" jsonschema:"minimum=0" jsonschema_description:"Optional maximum number of matches to replace, starting from the beginning of the file. 0 or omitted replaces all."`
+}
+
+// RegexReplace replaces up to count matches of a regular expression in a file
+func RegexReplace(ctx context.Context, input json.RawMessage) (string, error) {
+	var in RegexReplaceInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", err
+	}
+	if in.Path == "" || in.Pattern == "" {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "path and pattern must not be empty")
+	}
+	if in.Count < 0 {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "count must not be negative")
+	}
+	re, err := regexp.Compile(in.Pattern)
+	if err != nil {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "invalid regular expression: %w", err)
+	}
+
+	p, err := resolvePath(in.Path)
+	if err != nil {
+		return "", err
+	}
+	defer lockPaths(p)()
+	content, err := FS.ReadFile(p)
+	if err != nil {
+		return "", err
+	}
+
+	oldContent := string(content)
+	newContent, replacements := replaceMatches(re, oldContent, in.Replacement, in.Count)
+	if replacements == 0 {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "pattern matches nothing in %s", in.Path)
+	}
+
+	if DryRun {
+		return dryRunf("Would make %d replacements in %s:\n%s", replacements, in.Path, unifiedDiff(in.Path, oldContent, newContent)), nil
+	}
+	backup, err := backupFile(p)
+	if err != nil {
+		return "", err
+	}
+	if err := recordUndo(p); err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(p, []byte(newContent)); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Made %d replacements in %s%s:\n%s", replacements, in.Path, backupNote(backup), unifiedDiff(in.Path, oldContent, newContent)), nil
+}
+
+// replaceMatches replaces the first count matches of re in s, or all of them if count is 0,
+// expanding capture group references in replacement. It returns the result and the number of replacements.
+func replaceMatches(re *regexp.Regexp, s, replacement string, count int) (string, int) {
+	n := -1
+	if count > 0 {
+		n = count
+	}
+	matches := re.FindAllStringSubmatchIndex(s, n)
+	var sb strings.Builder
+	last := 0
+	for _, match := range matches {
+		sb.WriteString(s[last:match[0]])
+		sb.Write(re.ExpandString(nil, replacement, s, match))
+		last = match[1]
+	}
+	sb.WriteString(s[last:])
+	return sb.String(), len(matches)
+}
@@ -0,0 +1,209 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/MarkusZoppelt/oen/pkg/agent"
+)
+
+func init() {
+	agent.DefaultRegistry.Register(ReplaceAcrossFilesDefinition)
+}
+
+// ReplaceAcrossFilesDefinition allows replacing text in many files at once
+var ReplaceAcrossFilesDefinition = agent.ToolDefinition{
+	Name:                 "find_and_replace_across_files",
+	Description:          "Replace every occurrence of a literal string or regular expression in all text files whose path matches a glob. Returns how many replacements were made per file. Use this to rename a symbol across the project instead of editing files one by one.",
+	InputSchema:          GenerateSchema[ReplaceAcrossFilesInput](),
+	Function:             ReplaceAcrossFiles,
+	RequiresConfirmation: true,
+	ChangedPaths:         replacedPaths,
+}
+
+// ReplaceAcrossFilesInput holds input for find_and_replace_across_files tool
+type ReplaceAcrossFilesInput struct {
+	Pattern     string `json:"pattern" jsonschema_description:"The text or regular expression to replace."`
+	Replacement string `json:"replacement" jsonschema_description:"The replacement text. With regex, $1 etc. refer to capture groups."`
+	PathGlob    string `json:"path_glob,omitempty" jsonschema_description:"Optional glob selecting files, e.g. *.go or pkg/*/*.go. Globs without a slash match file names in any directory. Defaults to all files."`
+	Regex       bool   `json:"regex,omitempty" jsonschema_description:"Whether pattern is a regular expression. Defaults to a literal match."`
+}
+
+// ReplaceResult reports the replacements made in a single file
+type ReplaceResult struct {
+	File         string `json:"file"`
+	Replacements int    `json:"replacements"`
+	Error        string `json:"error,omitempty"`
+}
+
+// pendingReplace is a file change collected before anything is written
+type pendingReplace struct {
+	path     string
+	original string
+	content  string
+	result   ReplaceResult
+}
+
+// ReplaceAcrossFiles replaces a pattern in all matching files, computing every change before writing any
+func ReplaceAcrossFiles(ctx context.Context, input json.RawMessage) (string, error) {
+	var in ReplaceAcrossFilesInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", err
+	}
+	if in.Pattern == "" {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "pattern must not be empty")
+	}
+	if in.PathGlob != "" {
+		if _, err := filepath.Match(in.PathGlob, ""); err != nil {
+			return "", fmt.Errorf("invalid path_glob: %w", err)
+		}
+	}
+
+	replace := func(s string) (string, int) {
+		return strings.ReplaceAll(s, in.Pattern, in.Replacement), strings.Count(s, in.Pattern)
+	}
+	if in.Regex {
+		re, err := regexp.Compile(in.Pattern)
+		if err != nil {
+			return "", fmt.Errorf("invalid regular expression: %w", err)
+		}
+		replace = func(s string) (string, int) {
+			return re.ReplaceAllString(s, in.Replacement), len(re.FindAllStringIndex(s, -1))
+		}
+	}
+
+	root, err := resolvePath(".")
+	if err != nil {
+		return "", err
+	}
+
+	ignore, err := loadIgnoreRules()
+	if err != nil {
+		return "", err
+	}
+
+	var pending []pendingReplace
+	err = walk(root, func(pathStr string, info os.FileInfo, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+		if ignore.matches(pathStr) {
+			return skipEntry(info.IsDir())
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() || !matchesGlob(in.PathGlob, displayPath(pathStr)) {
+			return nil
+		}
+
+		content, err := FS.ReadFile(pathStr)
+		if err != nil {
+			return err
+		}
+		if looksBinary(content) {
+			return nil
+		}
+		newContent, count := replace(string(content))
+		if count == 0 || newContent == string(content) {
+			return nil
+		}
+		pending = append(pending, pendingReplace{
+			path:     pathStr,
+			original: string(content),
+			content:  newContent,
+			result:   ReplaceResult{File: displayPath(pathStr), Replacements: count},
+		})
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if !DryRun && len(pending) > 0 {
+		paths := make([]string, len(pending))
+		for i, change := range pending {
+			paths[i] = change.path
+		}
+		// Files are locked only once all are known, to lock them in a consistent order.
+		// Redo the replacement in any file that another tool changed in the meantime.
+		defer lockPaths(paths...)()
+		for i, change := range pending {
+			content, err := FS.ReadFile(change.path)
+			if err == nil && string(content) != change.original {
+				pending[i].content, pending[i].result.Replacements = replace(string(content))
+			}
+		}
+		if err := recordUndo(paths...); err != nil {
+			return "", err
+		}
+	}
+
+	results := make([]ReplaceResult, 0, len(pending))
+	var written, failed []string
+	for _, change := range pending {
+		if !DryRun {
+			if err := writeFileAtomic(change.path, []byte(change.content)); err != nil {
+				change.result.Error = err.Error()
+				failed = append(failed, change.result.File)
+			} else {
+				written = append(written, change.result.File)
+			}
+		}
+		results = append(results, change.result)
+	}
+
+	summary, err := json.Marshal(results)
+	if err != nil {
+		return "", err
+	}
+	if DryRun {
+		return dryRunf("Would replace in %d files: %s", len(results), summary), nil
+	}
+	if len(failed) > 0 {
+		return "", fmt.Errorf("failed to write %d of %d files (%s); these files were changed: [%s]; details: %s",
+			len(failed), len(pending), strings.Join(failed, ", "), strings.Join(written, ", "), summary)
+	}
+	return string(summary), nil
+}
+
+// matchesGlob reports whether the relative path matches glob; globs without a separator match the file name only
+func matchesGlob(glob, relPath string) bool {
+	if glob == "" {
+		return true
+	}
+	if !strings.Contains(glob, "/") {
+		relPath = filepath.Base(relPath)
+	}
+	matched, _ := filepath.Match(glob, filepath.ToSlash(relPath))
+	return matched
+}
+
+// replacedPaths reports the files a find_and_replace_across_files call changed, as listed in its output
+func replacedPaths(_ json.RawMessage, output string) []string {
+	if DryRun {
+		return nil
+	}
+	var results []ReplaceResult
+	if err := json.Unmarshal([]byte(output), &results); err != nil {
+		return nil
+	}
+	var paths []string
+	for _, result := range results {
+		if p, err := resolvePath(result.File); err == nil && result.Error == "" {
+			paths = append(paths, rootRelPath(p))
+		}
+	}
+	return paths
+}
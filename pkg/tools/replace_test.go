@@ -0,0 +1,97 @@
+package tools
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestReplaceAcrossFiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		input ReplaceAcrossFilesInput
+		want  map[string]string
+	}{
+		{
+			name:  "literal",
+			input: ReplaceAcrossFilesInput{Pattern: "old", Replacement: "new"},
+			want:  map[string]string{"a.go": "new new", "sub/b.txt": "new", "c.go": "other"},
+		},
+		{
+			name:  "glob",
+			input: ReplaceAcrossFilesInput{Pattern: "old", Replacement: "new", PathGlob: "*.go"},
+			want:  map[string]string{"a.go": "new new", "sub/b.txt": "old", "c.go": "other"},
+		},
+		{
+			name:  "regex",
+			input: ReplaceAcrossFilesInput{Pattern: `o(th)?er`, Replacement: "[$1]", Regex: true},
+			want:  map[string]string{"a.go": "old old", "sub/b.txt": "old", "c.go": "[th]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMemFS(t)
+			writeFiles(t, map[string]string{
+				IgnoreFileName:   "secret.txt\n",
+				"a.go":           "old old",
+				"sub/b.txt":      "old",
+				"c.go":           "other",
+				"secret.txt":     "old",
+				".git/config.go": "old",
+			})
+			if err := FS.Chmod(filepath.Join(RootDir, "a.go"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := callTool(t, ReplaceAcrossFiles, tt.input); err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range tt.want {
+				if got := readFile(t, name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+			for _, name := range []string{"secret.txt", ".git/config.go"} {
+				if got := readFile(t, name); got != "old" {
+					t.Errorf("%s = %q, want it untouched", name, got)
+				}
+			}
+			if info, err := FS.Stat(filepath.Join(RootDir, "a.go")); err != nil || info.Mode().Perm() != 0755 {
+				t.Errorf("mode of a.go = %v (%v), want 0755 kept", info.Mode().Perm(), err)
+			}
+		})
+	}
+}
+
+func TestReplaceAcrossFilesConcurrentEdit(t *testing.T) {
+	useMemFS(t)
+	oldStack := undoStack
+	t.Cleanup(func() { undoStack = oldStack })
+
+	for i := range 50 {
+		writeFiles(t, map[string]string{"a.go": "foo bar", "b.go": "foo"})
+		var wg sync.WaitGroup
+		errs := make([]error, 2)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, errs[0] = callTool(t, ReplaceAcrossFiles, ReplaceAcrossFilesInput{Pattern: "foo", Replacement: "FOO"})
+		}()
+		go func() {
+			defer wg.Done()
+			_, errs[1] = callTool(t, EditFile, EditFileInput{Path: "a.go", OldStr: "bar", NewStr: "BAR"})
+		}()
+		wg.Wait()
+		for _, err := range errs {
+			if err != nil {
+				t.Fatalf("run %d: %v", i, err)
+			}
+		}
+		if got := readFile(t, "a.go"); got != "FOO BAR" {
+			t.Fatalf("run %d: a.go = %q, want both edits kept", i, got)
+		}
+		if got := readFile(t, "b.go"); got != "FOO" {
+			t.Fatalf("run %d: b.go = %q, want %q", i, got, "FOO")
+		}
+	}
+}
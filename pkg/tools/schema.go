@@ -5,7 +5,10 @@ import (
 	"github.com/invopop/jsonschema"
 )
 
-// GenerateSchema generates a JSON schema for a given type T
+// GenerateSchema generates a JSON schema for a given type T.
+// Besides jsonschema_description, fields may carry jsonschema tags such as
+// enum=a,enum=b, minimum=n, maximum=n, or pattern=re, which end up in the
+// property schemas and are enforced when the model calls the tool.
 func GenerateSchema[T any]() anthropic.ToolInputSchemaParam {
 	reflector := jsonschema.Reflector{
 		AllowAdditionalProperties: false,
@@ -15,7 +18,11 @@ func GenerateSchema[T any]() anthropic.ToolInputSchemaParam {
 
 	schema := reflector.Reflect(v)
 
-	return anthropic.ToolInputSchemaParam{
+	inputSchema := anthropic.ToolInputSchemaParam{
 		Properties: schema.Properties,
 	}
+	if len(schema.Required) > 0 {
+		inputSchema.WithExtraFields(map[string]any{"required": schema.Required})
+	}
+	return inputSchema
 }
@@ -0,0 +1,49 @@
+package tools
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestGenerateSchemaRequired(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema map[string]any
+		want   []string
+	}{
+		{name: "make_directory", schema: mustSchemaMap(t, MakeDirectoryDefinition.InputSchemaMap), want: []string{"path"}},
+		{name: "remove_directory", schema: mustSchemaMap(t, RemoveDirectoryDefinition.InputSchemaMap), want: []string{"path"}},
+		{name: "search_files", schema: mustSchemaMap(t, SearchFilesDefinition.InputSchemaMap), want: []string{"pattern"}},
+		{name: "compare_files", schema: mustSchemaMap(t, CompareFilesDefinition.InputSchemaMap), want: []string{"path_a", "path_b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			required, _ := tt.schema["required"].([]any)
+			for _, name := range required {
+				got = append(got, name.(string))
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("required = %v, want %v", got, tt.want)
+			}
+			properties, _ := tt.schema["properties"].(map[string]any)
+			for _, name := range tt.want {
+				if _, ok := properties[name]; !ok {
+					t.Errorf("required property %s is missing from %v", name, properties)
+				}
+			}
+		})
+	}
+}
+
+// mustSchemaMap returns the input schema of a tool as a plain JSON object
+func mustSchemaMap(t *testing.T, schemaMap func() (map[string]any, error)) map[string]any {
+	t.Helper()
+	schema, err := schemaMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schema
+}
@@ -0,0 +1,203 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/MarkusZoppelt/oen/pkg/agent"
+)
+
+// defaultMaxSearchResults caps search results when no max is given
+const defaultMaxSearchResults = 100
+
+// binarySniffLen is the number of leading bytes checked for NUL bytes
+const binarySniffLen = 8 * 1024
+
+func init() {
+	agent.DefaultRegistry.Register(SearchFilesDefinition)
+}
+
+// SearchFilesDefinition allows searching file contents for a pattern
+var SearchFilesDefinition = agent.ToolDefinition{
+	Name:        "search_files",
+	Description: "Search for a literal string or regular expression in all text files under a given relative path. Returns matching lines with file names and line numbers. Use this instead of reading files one by one to find where something appears.",
+	InputSchema: GenerateSchema[SearchFilesInput](),
+	Function:    SearchFiles,
+	ReadOnly:    true,
+}
+
+// SearchFilesInput holds input for search_files tool
+type SearchFilesInput struct {
+	Pattern    string `json:"pattern" jsonschema_description:"The text or regular expression to search for."`
+	Path       string `json:"path,omitempty" jsonschema_description:"Optional relative path to search in. Defaults to current directory if not provided."`
+	Regex      bool   `json:"regex,omitempty" jsonschema_description:"Whether pattern is a regular expression. Defaults to a literal match."`
+	IgnoreCase bool   `json:"ignore_case,omitempty" jsonschema_description:"Whether the match should be case-insensitive."`
+	MaxResults int    `json:"max_results,omitempty" jsonschema:"minimum=0" jsonschema_description:"Optional maximum number of matches to return. Defaults to 100."`
+}
+
+// SearchMatch is a single line matched by search_files
+type SearchMatch struct {
+	File       string `json:"file"`
+	LineNumber int    `json:"line_number"`
+	Line       string `json:"line"`
+}
+
+// SearchFiles searches files for lines matching a pattern
+func SearchFiles(ctx context.Context, input json.RawMessage) (string, error) {
+	var in SearchFilesInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", err
+	}
+	if in.Pattern == "" {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "pattern must not be empty")
+	}
+
+	match, err := newLineMatcher(in.Pattern, in.Regex, in.IgnoreCase)
+	if err != nil {
+		return "", err
+	}
+
+	dir, err := resolvePath(in.Path)
+	if err != nil {
+		return "", err
+	}
+	maxResults := defaultMaxSearchResults
+	if in.MaxResults > 0 {
+		maxResults = in.MaxResults
+	}
+
+	ignore, err := loadIgnoreRules()
+	if err != nil {
+		return "", err
+	}
+
+	matches := []SearchMatch{}
+	err = walk(dir, func(pathStr string, info os.FileInfo, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			if pathStr == dir {
+				return err
+			}
+			// An unreadable entry shouldn't keep the rest of the tree from being searched.
+			return skipEntry(info != nil && info.IsDir())
+		}
+		// Symlinks are read through, so their targets must not be ignored either.
+		if ignore.blocks(pathStr) {
+			return skipEntry(info.IsDir())
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			// Symlinks to files are searched, but not those to directories, which may lead back up
+			// the tree, nor devices, sockets, and pipes, which may never end.
+			target, err := FS.Stat(pathStr)
+			if err != nil || !target.Mode().IsRegular() {
+				return nil
+			}
+		}
+
+		fileMatches, err := searchFile(pathStr, match, maxResults-len(matches))
+		if err != nil {
+			if pathStr == dir {
+				return err
+			}
+			return nil
+		}
+		matches = append(matches, fileMatches...)
+		if len(matches) >= maxResults {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	result, err := json.Marshal(matches)
+	if err != nil {
+		return "", err
+	}
+	return string(result), nil
+}
+
+// newLineMatcher builds a line predicate for a literal or regex pattern
+func newLineMatcher(pattern string, regex, ignoreCase bool) (func(string) bool, error) {
+	if regex {
+		if ignoreCase {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression: %w", err)
+		}
+		return re.MatchString, nil
+	}
+	if ignoreCase {
+		lower := strings.ToLower(pattern)
+		return func(line string) bool {
+			return strings.Contains(strings.ToLower(line), lower)
+		}, nil
+	}
+	return func(line string) bool {
+		return strings.Contains(line, pattern)
+	}, nil
+}
+
+// searchFile returns up to limit matching lines of a text file
+func searchFile(filePath string, match func(string) bool, limit int) ([]SearchMatch, error) {
+	f, err := FS.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReaderSize(f, binarySniffLen)
+	head, err := reader.Peek(binarySniffLen)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if looksBinary(head) {
+		return nil, nil
+	}
+
+	// Lines are read whole, however long, as minified or generated files often have very long ones.
+	var matches []SearchMatch
+	lineNumber := 0
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read %s: %w", displayPath(filePath), err)
+		}
+		if line == "" && err == io.EOF {
+			break
+		}
+		lineNumber++
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if match(line) {
+			matches = append(matches, SearchMatch{File: displayPath(filePath), LineNumber: lineNumber, Line: line})
+			if len(matches) >= limit {
+				break
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	return matches, nil
+}
+
+// looksBinary reports whether data contains a NUL byte within its first binarySniffLen bytes
+func looksBinary(data []byte) bool {
+	return bytes.IndexByte(data[:min(len(data), binarySniffLen)], 0) != -1
+}
@@ -0,0 +1,124 @@
+package tools
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSearchFiles(t *testing.T) {
+	long := strings.Repeat("x", 200*1024)
+	tests := []struct {
+		name  string
+		input SearchFilesInput
+		want  []SearchMatch
+	}{
+		{
+			name:  "literal",
+			input: SearchFilesInput{Pattern: "needle"},
+			want: []SearchMatch{
+				{File: "a.txt", LineNumber: 2, Line: "a needle"},
+				{File: "sub/b.txt", LineNumber: 1, Line: "needle in b"},
+			},
+		},
+		{
+			name:  "ignore case",
+			input: SearchFilesInput{Pattern: "NEEDLE", IgnoreCase: true, Path: "sub"},
+			want:  []SearchMatch{{File: "sub/b.txt", LineNumber: 1, Line: "needle in b"}},
+		},
+		{
+			name:  "regex",
+			input: SearchFilesInput{Pattern: `^needle\b`, Regex: true},
+			want:  []SearchMatch{{File: "sub/b.txt", LineNumber: 1, Line: "needle in b"}},
+		},
+		{
+			name:  "max results",
+			input: SearchFilesInput{Pattern: "needle", MaxResults: 1},
+			want:  []SearchMatch{{File: "a.txt", LineNumber: 2, Line: "a needle"}},
+		},
+		{
+			name:  "long line",
+			input: SearchFilesInput{Pattern: "end of long", Path: "long.txt"},
+			want:  []SearchMatch{{File: "long.txt", LineNumber: 2, Line: long + "end of long"}},
+		},
+		{
+			name:  "after long line",
+			input: SearchFilesInput{Pattern: "after", Path: "long.txt"},
+			want:  []SearchMatch{{File: "long.txt", LineNumber: 3, Line: "after"}},
+		},
+		{
+			name:  "windows line endings",
+			input: SearchFilesInput{Pattern: "crlf", Path: "crlf.txt"},
+			want:  []SearchMatch{{File: "crlf.txt", LineNumber: 2, Line: "crlf"}},
+		},
+		{
+			name:  "binary files skipped",
+			input: SearchFilesInput{Pattern: "binary"},
+			want:  []SearchMatch{},
+		},
+	}
+
+	useMemFS(t)
+	writeFiles(t, map[string]string{
+		"a.txt":      "first\na needle\n",
+		"sub/b.txt":  "needle in b",
+		"long.txt":   "short\n" + long + "end of long\nafter\n",
+		"crlf.txt":   "one\r\ncrlf\r\n",
+		"binary.bin": "binary\x00",
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := callTool(t, SearchFiles, tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []SearchMatch
+			if err := json.Unmarshal([]byte(output), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("matches = %.200v, want %.200v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchFilesSkipsSpecialEntries(t *testing.T) {
+	root := useTempDir(t)
+	writeFiles(t, map[string]string{"a.txt": "needle", "sub/b.txt": "needle"})
+	symlink(t, ".", "loop")
+	symlink(t, "sub", "to-sub")
+	symlink(t, "a.txt", "to-a")
+	symlink(t, "missing.txt", "dangling")
+	unreadable := filepath.Join(root, "unreadable.txt")
+	if err := os.WriteFile(unreadable, []byte("needle"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := callTool(t, SearchFiles, SearchFilesInput{Pattern: "needle"})
+	if err != nil {
+		t.Fatalf("search_files = %v, want the other entries searched", err)
+	}
+	var got []SearchMatch
+	if err := json.Unmarshal([]byte(output), &got); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]bool{}
+	for _, m := range got {
+		files[m.File] = true
+	}
+	for _, want := range []string{"a.txt", "sub/b.txt", "to-a"} {
+		if !files[want] {
+			t.Errorf("no match in %s, got %v", want, got)
+		}
+	}
+	for _, skipped := range []string{"loop/a.txt", "to-sub/b.txt"} {
+		if files[skipped] {
+			t.Errorf("searched %s below a symlinked directory", skipped)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/MarkusZoppelt/oen/pkg/agent"
+)
+
+// defaultTailLines is the number of lines tail_file returns when no count is given
+const defaultTailLines = 10
+
+// tailChunkSize is how much tail_file reads at a time while scanning backwards from the end of a file
+const tailChunkSize = 8192
+
+func init() {
+	agent.DefaultRegistry.Register(TailFileDefinition)
+}
+
+// TailFileDefinition allows reading the end of a file, such as a log, without loading all of it
+var TailFileDefinition = agent.ToolDefinition{
+	Name:        "tail_file",
+	Description: "Read the last lines of a file, like the tail command. Use this for log files and other large files where only the end matters; it doesn't read the rest of the file.",
+	InputSchema: GenerateSchema[TailFileInput](),
+	Function:    TailFile,
+	ReadOnly:    true,
+}
+
+// TailFileInput holds input for tail_file tool
+type TailFileInput struct {
+	Path  string `json:"path" jsonschema_description:"The relative path of a file in the working directory."`
+	Lines int    `json:"lines,omitempty" jsonschema:"minimum=1" jsonschema_description:"Optional number of lines to return. Defaults to 10."`
+}
+
+// TailFile returns the last lines of a file
+func TailFile(ctx context.Context, input json.RawMessage) (string, error) {
+	var in TailFileInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", err
+	}
+	if in.Lines < 0 {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "lines must be at least 1")
+	}
+	lines := defaultTailLines
+	if in.Lines > 0 {
+		lines = in.Lines
+	}
+
+	p, err := resolvePath(in.Path)
+	if err != nil {
+		return "", err
+	}
+	f, err := FS.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "%s is a directory, use list_files instead", in.Path)
+	}
+
+	tail, err := readTail(f, info.Size(), lines)
+	if err != nil {
+		return "", err
+	}
+	if len(tail) == 0 {
+		return fmt.Sprintf("%s is empty", in.Path), nil
+	}
+	if tail[len(tail)-1] != '\n' {
+		tail = append(tail, '\n')
+	}
+	return fmt.Sprintf("Last %d lines of %s:\n%s", bytes.Count(tail, []byte("\n")), in.Path, tail), nil
+}
+
+// readTail reads backwards from the end of r in chunks until it has found the last n lines.
+// A trailing newline ends the last line rather than starting an empty one.
+func readTail(r io.ReaderAt, size int64, n int) ([]byte, error) {
+	var tail []byte
+	end := size
+	for end > 0 {
+		start := max(end-tailChunkSize, 0)
+		chunk := make([]byte, end-start)
+		if _, err := r.ReadAt(chunk, start); err != nil && err != io.EOF {
+			return nil, err
+		}
+		tail = append(chunk, tail...)
+		end = start
+
+		// tail always reaches the end of the file, so a newline at its end terminates the last line
+		body := bytes.TrimSuffix(tail, []byte("\n"))
+		if i := nthLastIndex(body, '\n', n); i >= 0 {
+			return tail[i+1:], nil
+		}
+	}
+	return tail, nil
+}
+
+// nthLastIndex returns the index of the nth last occurrence of c in b, or -1 if there are fewer
+func nthLastIndex(b []byte, c byte, n int) int {
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] == c {
+			n--
+			if n == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,189 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/MarkusZoppelt/oen/pkg/agent"
+)
+
+// TemplatesDir is the directory render_template loads templates from. A relative path is relative to RootDir.
+var TemplatesDir = ".oen-templates"
+
+// templateExt is the optional extension of template files, which template names can leave out
+const templateExt = ".tmpl"
+
+// maxMissingVars bounds how often a template is executed again to find every var it lacks
+const maxMissingVars = 100
+
+// missingKeyPattern extracts the key from the error text/template returns for a missing map key
+var missingKeyPattern = regexp.MustCompile(`map has no entry for key "([^"]*)"`)
+
+func init() {
+	agent.DefaultRegistry.Register(RenderTemplateDefinition)
+}
+
+// RenderTemplateDefinition allows creating files from templates
+var RenderTemplateDefinition = agent.ToolDefinition{
+	Name:                 "render_template",
+	Description:          "Create a file from a named Go text/template in the templates directory, filling in vars, e.g. {{.Package}} with vars {\"Package\": \"main\"}. Every var the template uses as {{.Name}} is required. Fails if output_path exists unless overwrite is true. An unknown template_name fails with the list of available templates.",
+	InputSchema:          GenerateSchema[RenderTemplateInput](),
+	Function:             RenderTemplate,
+	RequiresConfirmation: true,
+	ChangedPaths:         changedInputPaths("output_path"),
+}
+
+// RenderTemplateInput holds input for render_template tool
+type RenderTemplateInput struct {
+	TemplateName string         `json:"template_name" jsonschema_description:"The name of the template, its file name in the templates directory with or without the .tmpl extension."`
+	OutputPath   string         `json:"output_path" jsonschema_description:"The relative path of the file to create."`
+	Vars         map[string]any `json:"vars,omitempty" jsonschema_description:"The values of the template's variables by name."`
+	Overwrite    bool           `json:"overwrite,omitempty" jsonschema_description:"Whether to replace an existing file at output_path. Defaults to false."`
+}
+
+// RenderTemplate executes a template with the given vars and writes the result to a file
+func RenderTemplate(ctx context.Context, input json.RawMessage) (string, error) {
+	var in RenderTemplateInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", err
+	}
+	if in.TemplateName == "" || in.OutputPath == "" {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "template_name and output_path must not be empty")
+	}
+
+	tmpl, err := loadTemplate(in.TemplateName)
+	if err != nil {
+		return "", err
+	}
+	content, err := executeTemplate(tmpl, in.Vars)
+	if err != nil {
+		return "", err
+	}
+
+	p, err := resolvePath(in.OutputPath)
+	if err != nil {
+		return "", err
+	}
+	defer lockPaths(p)()
+	if info, err := FS.Stat(p); err == nil {
+		if info.IsDir() {
+			return "", agent.NewToolError(agent.ToolErrorInvalidInput, "%s is a directory", in.OutputPath)
+		}
+		if !in.Overwrite {
+			return "", agent.NewToolError(agent.ToolErrorAlreadyExists, "%s already exists, set overwrite to replace it", in.OutputPath)
+		}
+	}
+	if DryRun {
+		return dryRunf("Would write %d bytes from template %s to %s:\n%s", len(content), in.TemplateName, in.OutputPath, content), nil
+	}
+	backup, err := backupFile(p)
+	if err != nil {
+		return "", err
+	}
+	if err := recordUndo(p); err != nil {
+		return "", err
+	}
+	if err := createNewFile(p, content); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Successfully wrote %d bytes from template %s to %s%s", len(content), in.TemplateName, in.OutputPath, backupNote(backup)), nil
+}
+
+// templatesDir returns the absolute path of TemplatesDir
+func templatesDir() (string, error) {
+	if filepath.IsAbs(TemplatesDir) {
+		return TemplatesDir, nil
+	}
+	root, err := filepath.Abs(RootDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve root directory: %w", err)
+	}
+	return filepath.Join(root, TemplatesDir), nil
+}
+
+// loadTemplate parses the template called name in the templates directory, whose file may add the .tmpl extension
+func loadTemplate(name string) (*template.Template, error) {
+	if !filepath.IsLocal(name) {
+		return nil, agent.NewToolError(agent.ToolErrorInvalidInput, "invalid template name %q", name)
+	}
+	dir, err := templatesDir()
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range []string{name, name + templateExt} {
+		content, err := FS.ReadFile(filepath.Join(dir, file))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Missing vars are an error so that a template never silently renders "<no value>".
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(string(content))
+		if err != nil {
+			return nil, agent.NewToolError(agent.ToolErrorFailed, "failed to parse template %s: %w", name, err)
+		}
+		return tmpl, nil
+	}
+
+	available, err := templateNames(dir)
+	if err != nil {
+		return nil, agent.NewToolError(agent.ToolErrorNotFound, "template %s not found: %w", name, err)
+	}
+	if len(available) == 0 {
+		return nil, agent.NewToolError(agent.ToolErrorNotFound, "template %s not found, %s has no templates", name, displayPath(dir))
+	}
+	return nil, agent.NewToolError(agent.ToolErrorNotFound, "template %s not found, available templates: %s", name, strings.Join(available, ", "))
+}
+
+// templateNames returns the sorted names of the templates in dir, without the .tmpl extension
+func templateNames(dir string) ([]string, error) {
+	entries, err := FS.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, strings.TrimSuffix(entry.Name(), templateExt))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// executeTemplate renders tmpl with vars. If vars lacks some the template uses, the error names all of them.
+func executeTemplate(tmpl *template.Template, vars map[string]any) (string, error) {
+	data := map[string]any{}
+	for name, value := range vars {
+		data[name] = value
+	}
+	var missing []string
+	for {
+		var buf bytes.Buffer
+		err := tmpl.Execute(&buf, data)
+		match := missingKeyPattern.FindStringSubmatch(fmt.Sprint(err))
+		if err != nil && match != nil && len(missing) < maxMissingVars {
+			// Fill the var in and go on to find the next one.
+			missing = append(missing, match[1])
+			data[match[1]] = ""
+			continue
+		}
+		switch {
+		case len(missing) > 0:
+			return "", agent.NewToolError(agent.ToolErrorInvalidInput, "template %s needs vars that weren't given: %s", tmpl.Name(), strings.Join(missing, ", "))
+		case err != nil:
+			return "", agent.NewToolError(agent.ToolErrorFailed, "failed to render template %s: %w", tmpl.Name(), err)
+		}
+		return buf.String(), nil
+	}
+}
@@ -0,0 +1,88 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Trash makes deleting tools move things into a trash directory under RootDir instead of removing them
+var Trash bool
+
+// trashDirName is the directory under RootDir that deleted files are moved to
+const trashDirName = ".oen-trash"
+
+// trashManifestName is the file in the trash directory that records where each entry came from
+const trashManifestName = "manifest.json"
+
+// TrashEntry records a path moved to the trash so that it can be restored
+type TrashEntry struct {
+	// TrashPath and OriginalPath are relative to RootDir
+	TrashPath    string    `json:"trash_path"`
+	OriginalPath string    `json:"original_path"`
+	DeletedAt    time.Time `json:"deleted_at"`
+}
+
+var trashMu sync.Mutex
+
+// moveToTrash moves p into the trash directory, records it in the manifest and for undo, and returns its new path
+func moveToTrash(p string) (string, error) {
+	root, err := filepath.Abs(RootDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve root directory: %w", err)
+	}
+	dir := filepath.Join(root, trashDirName)
+	if p == dir || strings.HasPrefix(p, dir+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is in the trash directory and can't be moved to the trash", displayPath(p))
+	}
+
+	trashMu.Lock()
+	defer trashMu.Unlock()
+	created, err := snapshotFiles(missingDirs(dir)...)
+	if err != nil {
+		return "", err
+	}
+	if err := FS.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create trash directory: %w", err)
+	}
+
+	now := time.Now()
+	name := now.Format("20060102-150405") + "-" + filepath.Base(p)
+	dst := filepath.Join(dir, name)
+	for i := 2; ; i++ {
+		if _, err := FS.Lstat(dst); os.IsNotExist(err) {
+			break
+		}
+		dst = filepath.Join(dir, fmt.Sprintf("%s-%d", name, i))
+	}
+	if err := FS.Rename(p, dst); err != nil {
+		return "", fmt.Errorf("failed to move to trash: %w", err)
+	}
+
+	manifestPath := filepath.Join(dir, trashManifestName)
+	manifest, err := snapshotFiles(manifestPath)
+	if err != nil {
+		return "", err
+	}
+	var entries []TrashEntry
+	if data, err := FS.ReadFile(manifestPath); err == nil {
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return "", fmt.Errorf("failed to read trash manifest: %w", err)
+		}
+	}
+	entries = append(entries, TrashEntry{TrashPath: rootRelPath(dst), OriginalPath: rootRelPath(p), DeletedAt: now})
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(manifestPath, data); err != nil {
+		return "", fmt.Errorf("failed to write trash manifest: %w", err)
+	}
+	pushUndo(slices.Concat(created, manifest, []fileSnapshot{{path: p, movedTo: dst}}))
+	return dst, nil
+}
@@ -0,0 +1,134 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/MarkusZoppelt/oen/pkg/agent"
+)
+
+// defaultTreeDepth limits directory_tree when no max depth is given
+const defaultTreeDepth = 3
+
+// maxTreeEntries caps the entries printed by directory_tree so huge trees don't flood the context
+const maxTreeEntries = 1000
+
+func init() {
+	agent.DefaultRegistry.Register(DirectoryTreeDefinition)
+}
+
+// DirectoryTreeDefinition allows viewing a directory hierarchy at a glance
+var DirectoryTreeDefinition = agent.ToolDefinition{
+	Name:        "directory_tree",
+	Description: "Show the directory hierarchy under a relative path as an indented tree, like the tree command. Use this to get an overview of a project's layout in one call instead of calling list_files repeatedly.",
+	InputSchema: GenerateSchema[DirectoryTreeInput](),
+	Function:    DirectoryTree,
+	ReadOnly:    true,
+}
+
+// DirectoryTreeInput holds input for directory_tree tool
+type DirectoryTreeInput struct {
+	Path      string `json:"path,omitempty" jsonschema_description:"Optional relative path of the directory to show. Defaults to current directory if not provided."`
+	MaxDepth  int    `json:"max_depth,omitempty" jsonschema:"minimum=0" jsonschema_description:"Optional maximum depth to descend into, where 1 shows only direct children. Defaults to 3."`
+	ShowSizes bool   `json:"show_sizes,omitempty" jsonschema_description:"Whether to annotate files with their size."`
+}
+
+// DirectoryTree renders the directory hierarchy as an indented tree
+func DirectoryTree(ctx context.Context, input json.RawMessage) (string, error) {
+	var in DirectoryTreeInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", err
+	}
+	dir, err := resolvePath(in.Path)
+	if err != nil {
+		return "", err
+	}
+	info, err := FS.Stat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", agent.NewToolError(agent.ToolErrorInvalidInput, "%s is not a directory", in.Path)
+	}
+	maxDepth := defaultTreeDepth
+	if in.MaxDepth > 0 {
+		maxDepth = in.MaxDepth
+	}
+
+	name := displayPath(dir)
+	var sb strings.Builder
+	sb.WriteString(name + "/\n")
+	ignore, err := loadIgnoreRules()
+	if err != nil {
+		return "", err
+	}
+	entries := 0
+	if err := writeTree(ctx, &sb, dir, "", 1, maxDepth, in.ShowSizes, ignore, &entries); err != nil {
+		return "", err
+	}
+	if entries > maxTreeEntries {
+		fmt.Fprintf(&sb, "... truncated after %d entries, use a smaller max_depth or a subdirectory path\n", maxTreeEntries)
+	}
+	return sb.String(), nil
+}
+
+// writeTree writes the entries of dir below prefix, descending until maxDepth; entries counts the lines written so far
+func writeTree(ctx context.Context, sb *strings.Builder, dir, prefix string, depth, maxDepth int, showSizes bool, ignore *ignoreRules, entries *int) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	children, err := FS.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	children = slices.DeleteFunc(children, func(child os.DirEntry) bool {
+		return ignore.matches(filepath.Join(dir, child.Name()))
+	})
+	for i, child := range children {
+		*entries++
+		if *entries > maxTreeEntries {
+			return nil
+		}
+		connector, indent := "├── ", "│   "
+		if i == len(children)-1 {
+			connector, indent = "└── ", "    "
+		}
+
+		line := child.Name()
+		if child.IsDir() {
+			line += "/"
+		} else if showSizes {
+			if info, err := child.Info(); err == nil {
+				line += fmt.Sprintf(" (%s)", formatSize(info.Size()))
+			}
+		}
+		sb.WriteString(prefix + connector + line + "\n")
+
+		// .git holds thousands of objects that say nothing about the project layout.
+		if child.IsDir() && depth < maxDepth && child.Name() != ".git" {
+			if err := writeTree(ctx, sb, filepath.Join(dir, child.Name()), prefix+indent, depth+1, maxDepth, showSizes, ignore, entries); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// formatSize formats a byte count with a binary unit, e.g. 1.5 KB
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
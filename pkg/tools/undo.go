@@ -0,0 +1,248 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/MarkusZoppelt/oen/pkg/agent"
+)
+
+func init() {
+	agent.DefaultRegistry.Register(UndoDefinition)
+}
+
+// maxUndoDepth bounds how many changes can be undone, since every entry holds whole file contents
+const maxUndoDepth = 20
+
+// maxTreeSnapshotEntries and maxTreeSnapshotBytes bound the directory trees remove_directory keeps
+// in memory to restore them. Larger trees are removed without being recorded.
+const (
+	maxTreeSnapshotEntries = 1000
+	maxTreeSnapshotBytes   = 16 << 20
+)
+
+// errTreeTooLarge reports that a tree exceeds the snapshot limits
+var errTreeTooLarge = errors.New("tree too large to snapshot")
+
+// RecordUndo is whether changes are kept for undo. main turns it off when the undo tool isn't
+// selected, so that nothing is kept that can't be undone.
+var RecordUndo = true
+
+// fileSnapshot is the content of a file before a change, or its absence. Of a directory only the
+// mode is kept, and of a symlink its target.
+type fileSnapshot struct {
+	path    string
+	existed bool
+	content []byte
+	mode    os.FileMode
+	dir     bool
+	// link is the target of the symlink at path
+	link string
+	// movedTo, if set, is where the change moved the file or directory at path
+	movedTo string
+}
+
+var (
+	undoMu sync.Mutex
+	// undoStack holds one entry per tool call, each with the snapshots of every file the call changed
+	undoStack [][]fileSnapshot
+)
+
+// UndoDefinition allows reverting the most recent file change
+var UndoDefinition = agent.ToolDefinition{
+	Name:                 "undo",
+	Description:          "Undo the most recent change made by edit_file, write_file, replace_lines, regex_replace, apply_patch, append_to_file, find_and_replace_across_files, move_file, copy_file, render_template, chmod, symlink, make_directory, rename_directory, or remove_directory, restoring the affected files and directories to their previous state. Files and directories the change created are removed. Can be called repeatedly to undo earlier changes.",
+	InputSchema:          GenerateSchema[UndoInput](),
+	Function:             Undo,
+	RequiresConfirmation: true,
+}
+
+// UndoInput holds input for undo tool
+type UndoInput struct{}
+
+// Undo restores the files changed by the most recent recorded change
+func Undo(ctx context.Context, input json.RawMessage) (string, error) {
+	undoMu.Lock()
+	if len(undoStack) == 0 {
+		undoMu.Unlock()
+		return "", fmt.Errorf("nothing to undo")
+	}
+	snapshots := undoStack[len(undoStack)-1]
+	if DryRun {
+		undoMu.Unlock()
+		return dryRunf("Would undo the last change to %s", snapshotPaths(snapshots)), nil
+	}
+	undoStack = undoStack[:len(undoStack)-1]
+	remaining := len(undoStack)
+	// Tools take undoMu while holding their path locks, so it must be released before locking the paths.
+	undoMu.Unlock()
+
+	defer lockPaths(snapshotLockPaths(snapshots)...)()
+
+	// Backwards, so that files are removed before the directories that were created for them.
+	for _, snapshot := range slices.Backward(snapshots) {
+		if err := snapshot.restore(); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("Undid the last change to %s (%d more changes can be undone)", snapshotPaths(snapshots), remaining), nil
+}
+
+// restore puts the file or directory back the way the snapshot found it
+func (s fileSnapshot) restore() error {
+	switch {
+	case s.movedTo != "":
+		if err := FS.Rename(s.movedTo, s.path); err != nil {
+			return fmt.Errorf("failed to move %s back to %s: %w", displayPath(s.movedTo), displayPath(s.path), err)
+		}
+	case !s.existed:
+		if err := FS.Remove(s.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", displayPath(s.path), err)
+		}
+	case s.dir:
+		if err := FS.MkdirAll(s.path, s.mode); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", displayPath(s.path), err)
+		}
+		return FS.Chmod(s.path, s.mode)
+	default:
+		// The directory may have been removed along with the file.
+		if err := FS.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", displayPath(s.path), err)
+		}
+		if s.link != "" {
+			if err := FS.Symlink(s.link, s.path); err != nil && !os.IsExist(err) {
+				return fmt.Errorf("failed to restore %s: %w", displayPath(s.path), err)
+			}
+			return nil
+		}
+		if err := writeFileAtomic(s.path, s.content); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", displayPath(s.path), err)
+		}
+		return FS.Chmod(s.path, s.mode)
+	}
+	return nil
+}
+
+// recordUndo snapshots the files at paths before a tool changes them, so that Undo can restore them
+func recordUndo(paths ...string) error {
+	snapshots, err := snapshotFiles(paths...)
+	if err != nil {
+		return err
+	}
+	pushUndo(snapshots)
+	return nil
+}
+
+// recordMove records that a file or directory was moved from one path to another, so that Undo can move it back
+func recordMove(from, to string) {
+	pushUndo([]fileSnapshot{{path: from, movedTo: to}})
+}
+
+// snapshotFiles snapshots the files, or the modes of the directories, at paths, following symlinks
+func snapshotFiles(paths ...string) ([]fileSnapshot, error) {
+	snapshots := make([]fileSnapshot, 0, len(paths))
+	for _, p := range paths {
+		snapshot := fileSnapshot{path: p}
+		info, err := FS.Stat(p)
+		switch {
+		case os.IsNotExist(err):
+		case err != nil:
+			return nil, err
+		case info.IsDir():
+			snapshot.existed, snapshot.dir, snapshot.mode = true, true, info.Mode().Perm()
+		default:
+			content, err := FS.ReadFile(p)
+			if err != nil {
+				return nil, err
+			}
+			snapshot.existed, snapshot.content, snapshot.mode = true, content, info.Mode().Perm()
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, nil
+}
+
+// snapshotTree snapshots the directory p and everything below it, parents first, without
+// following symlinks. The snapshots hold the content of every file, so trees are only
+// snapshotted before they are removed for good, and it returns errTreeTooLarge past the
+// snapshot limits.
+func snapshotTree(ctx context.Context, p string) ([]fileSnapshot, error) {
+	var snapshots []fileSnapshot
+	var size int64
+	err := walkDir(p, func(entry string, d fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if size += info.Size(); len(snapshots) >= maxTreeSnapshotEntries || size > maxTreeSnapshotBytes {
+			return errTreeTooLarge
+		}
+		snapshot := fileSnapshot{path: entry, existed: true, mode: info.Mode().Perm()}
+		switch {
+		case d.IsDir():
+			snapshot.dir = true
+		case d.Type()&fs.ModeSymlink != 0:
+			if snapshot.link, err = FS.Readlink(entry); err != nil {
+				return err
+			}
+		case d.Type().IsRegular():
+			if snapshot.content, err = FS.ReadFile(entry); err != nil {
+				return err
+			}
+		default:
+			// Devices, sockets, and pipes can't be restored from a snapshot.
+			return nil
+		}
+		snapshots = append(snapshots, snapshot)
+		return nil
+	})
+	return snapshots, err
+}
+
+// pushUndo adds the snapshots of a change to the undo stack, if undo is recorded, and the journal
+func pushUndo(snapshots []fileSnapshot) {
+	undoMu.Lock()
+	defer undoMu.Unlock()
+	if RecordUndo {
+		undoStack = append(undoStack, snapshots)
+		if len(undoStack) > maxUndoDepth {
+			undoStack = undoStack[len(undoStack)-maxUndoDepth:]
+		}
+	}
+	DefaultJournal.record(snapshots)
+}
+
+// snapshotLockPaths returns the paths restoring the snapshots changes, for lockPaths
+func snapshotLockPaths(snapshots []fileSnapshot) []string {
+	var paths []string
+	for _, snapshot := range snapshots {
+		paths = append(paths, snapshot.path)
+		if snapshot.movedTo != "" {
+			paths = append(paths, snapshot.movedTo)
+		}
+	}
+	return paths
+}
+
+// snapshotPaths lists the paths of snapshots for reporting
+func snapshotPaths(snapshots []fileSnapshot) string {
+	paths := make([]string, len(snapshots))
+	for i, snapshot := range snapshots {
+		paths[i] = displayPath(snapshot.path)
+	}
+	return strings.Join(paths, ", ")
+}
@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// maxWatchedFiles bounds how many files StatWatcher compares, so that huge trees stay cheap to scan
+const maxWatchedFiles = 20000
+
+// fileStamp is what StatWatcher compares to detect that a file changed
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// StatWatcher detects changed files under RootDir and the WorkspaceRoots by comparing modification times and sizes
+// between scans. It implements agent.FileWatcher.
+type StatWatcher struct {
+	files map[string]fileStamp
+}
+
+// NewStatWatcher returns a StatWatcher that reports changes made after it was created
+func NewStatWatcher() *StatWatcher {
+	return &StatWatcher{files: scanFiles()}
+}
+
+// Changes returns the files created, modified, or deleted since the previous call, relative to RootDir
+func (w *StatWatcher) Changes() ([]string, error) {
+	files := scanFiles()
+	var changed []string
+	for p, stamp := range files {
+		if old, ok := w.files[p]; !ok || !old.modTime.Equal(stamp.modTime) || old.size != stamp.size {
+			changed = append(changed, p)
+		}
+	}
+	for p := range w.files {
+		if _, ok := files[p]; !ok {
+			changed = append(changed, p)
+		}
+	}
+	w.files = files
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// scanFiles stats the regular files under the roots, skipping .git, ignored paths, and anything unreadable
+func scanFiles() map[string]fileStamp {
+	files := map[string]fileStamp{}
+	all, err := roots()
+	if err != nil {
+		return files
+	}
+	ignore, err := loadIgnoreRules()
+	if err != nil {
+		return files
+	}
+	for _, root := range all {
+		scanRoot(root, ignore, files)
+	}
+	return files
+}
+
+// scanRoot adds the regular files under root to files, up to maxWatchedFiles in total
+func scanRoot(root string, ignore *ignoreRules, files map[string]fileStamp) {
+	walkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if ignore.matches(p) {
+			return skipEntry(d.IsDir())
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if len(files) >= maxWatchedFiles {
+			return filepath.SkipAll
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		files[rootRelPath(p)] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		return nil
+	})
+}